@@ -0,0 +1,422 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/reski-rukmantiyo/bdx-parser/pkg/inventory"
+	"github.com/reski-rukmantiyo/bdx-parser/pkg/pdustats"
+)
+
+// Anomaly types
+const (
+	AnomalySpike      = "spike"       // short reading far above the surrounding level
+	AnomalyDip        = "dip"         // short reading far below the surrounding level
+	AnomalyLevelShift = "level-shift" // lasting step change of the level
+	AnomalyOutage     = "outage"      // readings dropped to zero
+)
+
+const (
+	madScale         = 1.4826 // makes the MAD a consistent estimate of the standard deviation
+	minDeviation     = 0.05   // floor of the robust scale (A), so flat series don't flag meter noise
+	minShiftFraction = 0.1    // a level shift must also move the level by at least 10%
+	shiftScoreFactor = 0.5    // a shift moves a whole window, so it needs half the score of a single reading
+	minOutageSamples = 2      // consecutive zero readings needed for an outage
+	minWindowSamples = 8      // smallest usable rolling window
+)
+
+// anomalyTimeLayout formats event timestamps in the anomaly CSV
+const anomalyTimeLayout = "2006-01-02 15:04:05"
+
+// AnomalyOptions controls the anomaly detection over the raw series
+type AnomalyOptions struct {
+	Window    time.Duration // rolling window for spikes and level shifts
+	Threshold float64       // robust z-score (deviation / (1.4826 * MAD)) flagged as anomalous
+}
+
+// Validate checks the anomaly options
+func (opts AnomalyOptions) Validate() error {
+	if opts.Window <= 0 {
+		return fmt.Errorf("anomaly window must be positive")
+	}
+	if opts.Threshold <= 0 {
+		return fmt.Errorf("anomaly threshold must be positive")
+	}
+	return nil
+}
+
+// Anomaly is one detected event on a rack phase
+type Anomaly struct {
+	Rack      string
+	Phase     string
+	Type      string
+	Start     time.Time
+	End       time.Time
+	Value     float64 // most extreme reading (spike, dip), new level (level shift) or 0 (outage)
+	Baseline  float64 // expected level: rolling median, or the level before a shift or outage
+	Magnitude float64 // Value - Baseline
+	Score     float64 // robust z-score of the reading (spike, dip) or of the change (level shift); 0 for outages
+	Samples   int     // readings covered by the event
+}
+
+// anomalyFile names the extra output of --anomalies after the main output file
+func anomalyFile(outputFile string) string {
+	return strings.TrimSuffix(outputFile, filepath.Ext(outputFile)) + "_anomalies.csv"
+}
+
+// median returns the median of values, reordering them
+func median(values []float64) float64 {
+	sort.Float64s(values)
+	n := len(values)
+	if n%2 == 1 {
+		return values[n/2]
+	}
+	return (values[n/2-1] + values[n/2]) / 2
+}
+
+// windowSamples converts the window duration to a sample count
+func (dp *DataProcessor) windowSamples() (int, error) {
+	interval := dp.sampleInterval()
+	if interval <= 0 {
+		return 0, fmt.Errorf("no sampling interval detected")
+	}
+	w := int(dp.anomalies.Window / interval)
+	if w < minWindowSamples {
+		return 0, fmt.Errorf("window %s holds only %d samples at %s, need at least %d",
+			dp.anomalies.Window, w, formatInterval(interval), minWindowSamples)
+	}
+	return w, nil
+}
+
+// DetectAnomalies finds spikes, dips, level shifts and outages on every active rack phase
+func (dp *DataProcessor) DetectAnomalies() ([]Anomaly, error) {
+	w, err := dp.windowSamples()
+	if err != nil {
+		return nil, err
+	}
+
+	var events []Anomaly
+	for i := 1; i <= 18; i++ {
+		rackName := fmt.Sprintf("Q%d", i)
+		if dp.RackState(rackName) != inventory.RackActive {
+			continue
+		}
+
+		for _, lineType := range pdustats.Phases {
+			samples := append([]Sample(nil), dp.data[fmt.Sprintf("%s_%s", rackName, lineType)]...)
+			sort.SliceStable(samples, func(a, b int) bool { return samples[a].Time.Before(samples[b].Time) })
+
+			found := dp.seriesAnomalies(samples, w)
+			for j := range found {
+				found[j].Rack, found[j].Phase = rackName, lineType
+			}
+			events = append(events, found...)
+		}
+	}
+
+	// Per-rack list in time order
+	sort.SliceStable(events, func(a, b int) bool {
+		if events[a].Rack != events[b].Rack {
+			return rackNumber(events[a].Rack) < rackNumber(events[b].Rack)
+		}
+		return events[a].Start.Before(events[b].Start)
+	})
+	return events, nil
+}
+
+// rackNumber returns 7 for "Q7"
+func rackNumber(rackName string) int {
+	n, _ := strconv.Atoi(strings.TrimPrefix(rackName, "Q"))
+	return n
+}
+
+// seriesAnomalies runs every detector over one time-ordered series
+func (dp *DataProcessor) seriesAnomalies(samples []Sample, w int) []Anomaly {
+	// Spikes and shifts look at the readings only; zeros are outages, not load
+	var readings []Sample
+	for _, s := range samples {
+		if s.Value != 0 {
+			readings = append(readings, s)
+		}
+	}
+	values := sampleValues(readings)
+
+	events := dp.spikes(readings, values, w)
+	events = append(events, dp.levelShifts(readings, values, w)...)
+	events = append(events, dp.outages(samples, w)...)
+	return events
+}
+
+// sortedWindow keeps the readings of a rolling window in order, so the median and
+// MAD are available without re-sorting the window for every reading
+type sortedWindow struct {
+	values []float64
+}
+
+// add inserts a reading
+func (sw *sortedWindow) add(v float64) {
+	i := sort.SearchFloat64s(sw.values, v)
+	sw.values = append(sw.values, 0)
+	copy(sw.values[i+1:], sw.values[i:])
+	sw.values[i] = v
+}
+
+// remove deletes one occurrence of a reading added before
+func (sw *sortedWindow) remove(v float64) {
+	i := sort.SearchFloat64s(sw.values, v)
+	if i < len(sw.values) && sw.values[i] == v {
+		sw.values = append(sw.values[:i], sw.values[i+1:]...)
+	}
+}
+
+// len returns the number of readings in the window
+func (sw *sortedWindow) len() int {
+	return len(sw.values)
+}
+
+// level returns the median and the floored robust scale (1.4826 × MAD) of the window
+func (sw *sortedWindow) level() (float64, float64) {
+	n := len(sw.values)
+	med := sw.middle(func(k int) float64 { return sw.values[k] }, n)
+
+	// The deviations below and above the median form two ascending sequences;
+	// the MAD is their median, found by selection instead of sorting
+	split := sort.SearchFloat64s(sw.values, med)
+	below := func(i int) float64 { return med - sw.values[split-1-i] }
+	above := func(i int) float64 { return sw.values[split+i] - med }
+	deviation := func(k int) float64 { return kthOfTwo(below, split, above, n-split, k) }
+	mad := sw.middle(deviation, n)
+
+	return med, math.Max(madScale*mad, minDeviation)
+}
+
+// middle returns the median of n ordered values given by their rank
+func (sw *sortedWindow) middle(kth func(int) float64, n int) float64 {
+	if n%2 == 1 {
+		return kth(n / 2)
+	}
+	return (kth(n/2-1) + kth(n/2)) / 2
+}
+
+// kthOfTwo returns the k-th smallest (0-based) value of two ascending sequences
+func kthOfTwo(a func(int) float64, la int, b func(int) float64, lb int, k int) float64 {
+	lo, hi := k+1-lb, k+1
+	if lo < 0 {
+		lo = 0
+	}
+	if hi > la {
+		hi = la
+	}
+	for {
+		i := (lo + hi) / 2 // taken from a
+		j := k + 1 - i     // taken from b
+		switch {
+		case i < la && j > 0 && b(j-1) > a(i):
+			lo = i + 1
+		case i > 0 && j < lb && a(i-1) > b(j):
+			hi = i - 1
+		default:
+			switch {
+			case i == 0:
+				return b(j - 1)
+			case j == 0:
+				return a(i - 1)
+			}
+			return math.Max(a(i-1), b(j-1))
+		}
+	}
+}
+
+// spikes flags readings far from the median of the window centred on them
+// (excluding the reading itself); consecutive flagged readings form one event.
+// The centred window keeps step changes from showing up as spikes.
+func (dp *DataProcessor) spikes(readings []Sample, values []float64, w int) []Anomaly {
+	var events []Anomaly
+	var current *Anomaly
+	last := -2
+
+	// The window covers values[lo:hi], which includes the reading being scored
+	var window sortedWindow
+	lo, hi := 0, 0
+	for j, x := range values {
+		for ; hi < len(values) && hi <= j+w/2; hi++ {
+			window.add(values[hi])
+		}
+		for ; lo < j-w/2; lo++ {
+			window.remove(values[lo])
+		}
+		if window.len()-1 < minWindowSamples {
+			continue
+		}
+
+		window.remove(x)
+		med, scale := window.level()
+		window.add(x)
+
+		z := (x - med) / scale
+		if math.Abs(z) < dp.anomalies.Threshold {
+			continue
+		}
+
+		kind := AnomalySpike
+		if z < 0 {
+			kind = AnomalyDip
+		}
+		if current != nil && last == j-1 && current.Type == kind {
+			current.End = readings[j].Time
+			current.Samples++
+			if math.Abs(z) > math.Abs(current.Score) {
+				current.Value, current.Baseline, current.Magnitude, current.Score = x, med, x-med, z
+			}
+		} else {
+			events = append(events, Anomaly{Type: kind, Start: readings[j].Time, End: readings[j].Time,
+				Value: x, Baseline: med, Magnitude: x - med, Score: z, Samples: 1})
+			current = &events[len(events)-1]
+		}
+		last = j
+	}
+	return events
+}
+
+// levelShifts compares the median of the w readings before each point with the
+// median of the w readings from it on. The change is scored against the robust
+// scale of single readings, so slow cycles in smooth, autocorrelated load don't
+// count as shifts; as the whole window moved, half the spike score is enough.
+// Of a run of qualifying points with the same direction, the one with the largest
+// score marks the shift, and no further shift is reported within one window of it.
+func (dp *DataProcessor) levelShifts(readings []Sample, values []float64, w int) []Anomaly {
+	var events []Anomaly
+	var best *Anomaly
+	bestIndex, last := 0, -2
+
+	if len(values) < 2*w {
+		return nil
+	}
+	var beforeWindow, afterWindow sortedWindow
+	for k := 0; k < w; k++ {
+		beforeWindow.add(values[k])
+		afterWindow.add(values[w+k])
+	}
+
+	for j := w; j+w <= len(values); j++ {
+		if j > w {
+			beforeWindow.remove(values[j-w-1])
+			beforeWindow.add(values[j-1])
+			afterWindow.remove(values[j-1])
+			afterWindow.add(values[j+w-1])
+		}
+
+		before, beforeScale := beforeWindow.level()
+		after, afterScale := afterWindow.level()
+		delta := after - before
+		z := delta / math.Max(beforeScale, afterScale)
+
+		if math.Abs(z) < shiftScoreFactor*dp.anomalies.Threshold || math.Abs(delta) < minShiftFraction*before {
+			continue
+		}
+
+		candidate := Anomaly{Type: AnomalyLevelShift, Start: readings[j].Time, End: readings[j].Time,
+			Value: after, Baseline: before, Magnitude: delta, Score: z, Samples: w}
+		switch {
+		case best != nil && last == j-1 && (best.Score > 0) == (z > 0):
+			if math.Abs(z) > math.Abs(best.Score) {
+				*best = candidate
+				bestIndex = j
+			}
+		case best != nil && j < bestIndex+w:
+			continue // The same shift seen from further away
+		default:
+			events = append(events, candidate)
+			best = &events[len(events)-1]
+			bestIndex = j
+		}
+		last = j
+	}
+	return events
+}
+
+// outages reports runs of zero readings after the series has carried load;
+// the baseline is the median of the w readings before the drop
+func (dp *DataProcessor) outages(samples []Sample, w int) []Anomaly {
+	var events []Anomaly
+	var loaded []float64 // non-zero readings so far
+
+	for j := 0; j < len(samples); {
+		if samples[j].Value != 0 {
+			loaded = append(loaded, samples[j].Value)
+			j++
+			continue
+		}
+
+		end := j
+		for end+1 < len(samples) && samples[end+1].Value == 0 {
+			end++
+		}
+		if run := end - j + 1; run >= minOutageSamples && len(loaded) > 0 {
+			from := len(loaded) - w
+			if from < 0 {
+				from = 0
+			}
+			baseline := median(append([]float64(nil), loaded[from:]...))
+			events = append(events, Anomaly{Type: AnomalyOutage, Start: samples[j].Time, End: samples[end].Time,
+				Baseline: baseline, Magnitude: -baseline, Samples: run})
+		}
+		j = end + 1
+	}
+	return events
+}
+
+// anomalyRecords returns one row per event
+func (dp *DataProcessor) anomalyRecords(events []Anomaly) [][]string {
+	records := [][]string{{"pdu", "rack", "phase", "type", "start", "end", "duration",
+		"value", "baseline", "magnitude", "score", "samples"}}
+	for _, e := range events {
+		duration := "" // a level shift is a point in time
+		if e.Type != AnomalyLevelShift {
+			duration = (e.End.Sub(e.Start) + dp.sampleInterval()).String() // an event of one reading lasts one interval
+		}
+		score := ""
+		if e.Type != AnomalyOutage {
+			score = fmt.Sprintf("%.1f", e.Score)
+		}
+		records = append(records, []string{dp.pduName, e.Rack, e.Phase, e.Type,
+			e.Start.Format(anomalyTimeLayout), e.End.Format(anomalyTimeLayout), duration,
+			fmt.Sprintf("%.3f", e.Value), fmt.Sprintf("%.3f", e.Baseline), fmt.Sprintf("%+.3f", e.Magnitude),
+			score, strconv.Itoa(e.Samples)})
+	}
+	return records
+}
+
+// GenerateAnomalies writes the detected events next to the main output
+func (dp *DataProcessor) GenerateAnomalies(outputFile string) error {
+	if dp.unparsedTimestamps > 0 {
+		return fmt.Errorf("cannot detect anomalies: %d rows have unparseable timestamps", dp.unparsedTimestamps)
+	}
+
+	events, err := dp.DetectAnomalies()
+	if err != nil {
+		return err
+	}
+
+	filename := anomalyFile(outputFile)
+	if err := writeCSVFile(filename, dp.anomalyRecords(events)); err != nil {
+		return err
+	}
+
+	counts := make(map[string]int)
+	for _, e := range events {
+		counts[e.Type]++
+		if e.Type == AnomalyLevelShift || e.Type == AnomalyOutage {
+			fmt.Printf("⚠️  %s %s %s: %s at %s (%+.3f A)\n", dp.pduName, e.Rack, e.Phase, e.Type,
+				e.Start.Format(anomalyTimeLayout), e.Magnitude)
+		}
+	}
+	fmt.Printf("Anomalies written to %s: %d spikes, %d dips, %d level shifts, %d outages\n", filename,
+		counts[AnomalySpike], counts[AnomalyDip], counts[AnomalyLevelShift], counts[AnomalyOutage])
+	return nil
+}
@@ -0,0 +1,205 @@
+package main
+
+import (
+	"encoding/csv"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/reski-rukmantiyo/bdx-parser/pkg/inventory"
+	"github.com/reski-rukmantiyo/bdx-parser/pkg/pdustats"
+)
+
+// dateLayout is the calendar day format used for daily statistics
+const dateLayout = "2006-01-02"
+
+// DailyPeaks returns the highest non-zero reading of each phase across the active
+// racks for every day with parsed timestamps
+func (dp *DataProcessor) DailyPeaks() []pdustats.DailyPeak {
+	peaks := make(map[string]map[string]float64) // date -> phase -> peak
+
+	for i := 1; i <= 18; i++ {
+		rackName := fmt.Sprintf("Q%d", i)
+		if dp.RackState(rackName) != inventory.RackActive {
+			continue
+		}
+
+		for _, lineType := range pdustats.Phases {
+			for _, s := range dp.data[fmt.Sprintf("%s_%s", rackName, lineType)] {
+				if s.Time.IsZero() || s.Value == 0 {
+					continue
+				}
+				date := s.Time.Format(dateLayout)
+				if peaks[date] == nil {
+					peaks[date] = make(map[string]float64)
+				}
+				if s.Value > peaks[date][lineType] {
+					peaks[date][lineType] = s.Value
+				}
+			}
+		}
+	}
+
+	dates := make([]string, 0, len(peaks))
+	for date := range peaks {
+		dates = append(dates, date)
+	}
+	sort.Strings(dates)
+
+	result := make([]pdustats.DailyPeak, len(dates))
+	for i, date := range dates {
+		result[i] = pdustats.DailyPeak{Date: date, Phases: make(map[string]*float64)}
+		for _, lineType := range pdustats.Phases {
+			var value *float64
+			if peak, ok := peaks[date][lineType]; ok {
+				value = roundedValue(peak)
+			}
+			result[i].Phases[lineType] = value
+		}
+	}
+	return result
+}
+
+// dayTypes split the hourly profile into working days and weekends
+var dayTypes = []string{"weekday", "weekend"}
+
+// dayType classifies a timestamp as weekday or weekend
+func dayType(t time.Time) string {
+	if t.Weekday() == time.Saturday || t.Weekday() == time.Sunday {
+		return "weekend"
+	}
+	return "weekday"
+}
+
+// profileFiles names the extra outputs of --profiles after the main output file
+func profileFiles(outputFile string) (string, string) {
+	base := strings.TrimSuffix(outputFile, filepath.Ext(outputFile))
+	return base + "_daily.csv", base + "_profile.csv"
+}
+
+// GenerateProfiles writes daily min/avg/max per rack and phase, and the average load
+// by hour of day for weekdays and weekends, next to the main output
+func (dp *DataProcessor) GenerateProfiles(outputFile string) error {
+	if dp.unparsedTimestamps > 0 {
+		return fmt.Errorf("cannot build profiles: %d rows have unparseable timestamps", dp.unparsedTimestamps)
+	}
+	dailyFile, profileFile := profileFiles(outputFile)
+
+	if err := writeCSVFile(dailyFile, dp.dailyRecords()); err != nil {
+		return err
+	}
+	fmt.Printf("Daily statistics written to %s\n", dailyFile)
+
+	if err := writeCSVFile(profileFile, dp.profileRecords()); err != nil {
+		return err
+	}
+	fmt.Printf("Hourly profile written to %s\n", profileFile)
+	return nil
+}
+
+// dailyRecords returns one row per day, active rack and phase
+func (dp *DataProcessor) dailyRecords() [][]string {
+	records := [][]string{{"date", "pdu", "rack", "phase", "min", "avg", "max", "samples"}}
+
+	for i := 1; i <= 18; i++ {
+		rackName := fmt.Sprintf("Q%d", i)
+		if dp.RackState(rackName) != inventory.RackActive {
+			continue
+		}
+
+		for _, lineType := range pdustats.Phases {
+			byDay := make(map[string][]Sample)
+			var dates []string
+			for _, s := range dp.data[fmt.Sprintf("%s_%s", rackName, lineType)] {
+				date := s.Time.Format(dateLayout)
+				if _, ok := byDay[date]; !ok {
+					dates = append(dates, date)
+				}
+				byDay[date] = append(byDay[date], s)
+			}
+			sort.Strings(dates)
+
+			for _, date := range dates {
+				stats := dp.sampleStatistics(byDay[date])
+				record := []string{date, dp.pduName, rackName, lineType, noDataLabel, noDataLabel, noDataLabel, strconv.Itoa(stats.Count)}
+				if stats.Count > 0 {
+					record[4] = fmt.Sprintf("%.3f", stats.Min)
+					record[5] = fmt.Sprintf("%.3f", stats.Avg)
+					record[6] = fmt.Sprintf("%.3f", stats.Max)
+				}
+				records = append(records, record)
+			}
+		}
+	}
+
+	// Group by date first, as the file is read day by day
+	sort.SliceStable(records[1:], func(a, b int) bool { return records[a+1][0] < records[b+1][0] })
+	return records
+}
+
+// profileRecords returns the mean non-zero reading per hour of day for every active
+// rack, phase and day type
+func (dp *DataProcessor) profileRecords() [][]string {
+	header := []string{"pdu", "rack", "phase", "day_type"}
+	for hour := 0; hour < 24; hour++ {
+		header = append(header, fmt.Sprintf("h%02d", hour))
+	}
+	records := [][]string{header}
+
+	for i := 1; i <= 18; i++ {
+		rackName := fmt.Sprintf("Q%d", i)
+		if dp.RackState(rackName) != inventory.RackActive {
+			continue
+		}
+
+		for _, lineType := range pdustats.Phases {
+			var sums, counts [2][24]float64
+			for _, s := range dp.data[fmt.Sprintf("%s_%s", rackName, lineType)] {
+				if s.Value == 0 {
+					continue
+				}
+				kind := 0
+				if dayType(s.Time) == "weekend" {
+					kind = 1
+				}
+				sums[kind][s.Time.Hour()] += s.Value
+				counts[kind][s.Time.Hour()]++
+			}
+
+			for kind, name := range dayTypes {
+				record := []string{dp.pduName, rackName, lineType, name}
+				for hour := 0; hour < 24; hour++ {
+					if counts[kind][hour] == 0 {
+						record = append(record, noDataLabel)
+						continue
+					}
+					record = append(record, fmt.Sprintf("%.3f", sums[kind][hour]/counts[kind][hour]))
+				}
+				records = append(records, record)
+			}
+		}
+	}
+	return records
+}
+
+// writeCSVFile writes records to a CSV file, reporting write and flush errors
+func writeCSVFile(filename string, records [][]string) error {
+	file, err := os.Create(filename)
+	if err != nil {
+		return fmt.Errorf("failed to create %s: %v", filename, err)
+	}
+
+	writer := csv.NewWriter(file)
+	if err := writer.WriteAll(records); err != nil {
+		file.Close()
+		return fmt.Errorf("failed to write %s: %v", filename, err)
+	}
+	if err := file.Close(); err != nil {
+		return fmt.Errorf("failed to close %s: %v", filename, err)
+	}
+	return nil
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/xuri/excelize/v2"
+)
+
+// readExportRows reads the rows of the first sheet of a PDU export
+func readExportRows(filename string) ([][]string, error) {
+	f, err := excelize.OpenFile(filename)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open input file %s: %v", filename, err)
+	}
+	defer f.Close()
+
+	// Get the first sheet
+	sheets := f.GetSheetList()
+	if len(sheets) == 0 {
+		return nil, fmt.Errorf("no sheets found in %s", filename)
+	}
+
+	rows, err := f.GetRows(sheets[0])
+	if err != nil {
+		return nil, fmt.Errorf("failed to get rows from %s: %v", filename, err)
+	}
+	return rows, nil
+}
+
+// ExportPDUNames returns the PDU prefixes found in the header row, in order of first appearance
+func ExportPDUNames(rows [][]string) []string {
+	if len(rows) == 0 {
+		return nil
+	}
+
+	var names []string
+	seen := make(map[string]bool)
+	for i, header := range rows[0] {
+		if i == 0 {
+			continue // Skip timestamp column
+		}
+
+		// Same header shape as LoadRows: "A1 Q1 Current : l1"
+		parts := strings.Split(strings.TrimSpace(header), " ")
+		if len(parts) < 5 || seen[parts[0]] {
+			continue
+		}
+		seen[parts[0]] = true
+		names = append(names, parts[0])
+	}
+	return names
+}
+
+// ForPDU returns a new processor for one PDU with the same options as dp
+func (dp *DataProcessor) ForPDU(pduName string) *DataProcessor {
+	processor := NewDataProcessor()
+	processor.pduName = pduName
+	processor.inventory = dp.inventory
+	processor.resample = dp.resample
+	processor.avgMode = dp.avgMode
+	processor.avgMaxGap = dp.avgMaxGap
+	processor.format = dp.format
+	processor.summary = dp.summary
+	processor.profiles = dp.profiles
+	processor.store = dp.store
+	processor.anomalies = dp.anomalies
+	return processor
+}
+
+// perPDUOutputFile names the output of one PDU from a multi-PDU export.
+// Default names follow the PDU (total_b3.csv); an explicit name gets the PDU
+// appended (report.csv -> report_b3.csv).
+func perPDUOutputFile(outputFile string, explicit bool, pduName, format string) string {
+	if !explicit {
+		return fmt.Sprintf("total_%s.%s", strings.ToLower(pduName), format)
+	}
+
+	ext := filepath.Ext(outputFile)
+	return fmt.Sprintf("%s_%s%s", strings.TrimSuffix(outputFile, ext), strings.ToLower(pduName), ext)
+}
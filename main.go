@@ -24,52 +24,75 @@ import (
 	"encoding/csv"
 	"fmt"
 	"log"
+	"math"
 	"os"
+	"sort"
 	"strconv"
 	"strings"
+	"time"
 
-	"github.com/xuri/excelize/v2"
+	"github.com/reski-rukmantiyo/bdx-parser/pkg/inventory"
+	"github.com/reski-rukmantiyo/bdx-parser/pkg/pdustats"
 )
 
 // Statistics holds min, max, and average values
 type Statistics struct {
-	Min float64
-	Max float64
-	Avg float64
+	Min   float64
+	Max   float64
+	Avg   float64
+	P95   float64 // 95th percentile (nearest rank) of the non-zero samples
+	Count int     // number of non-zero samples used
 }
 
 // DataProcessor handles the Excel file processing
 type DataProcessor struct {
-	pduName string
-	data    map[string][]float64 // key: "Q1_l1", "Q1_l2", etc.
+	pduName            string
+	data               map[string][]Sample            // key: "Q1_l1", "Q1_l2", etc.
+	inventory          inventory.Inventory            // optional declared rack states
+	rackStates         map[string]inventory.RackState // key: "Q1", "Q2", etc.
+	interval           time.Duration                  // detected native sampling interval
+	unparsedTimestamps int                            // rows whose timestamp could not be parsed
+	resample           *ResampleOptions               // optional common grid applied before statistics
+	avgMode            string                         // AverageSample or AverageTime
+	avgMaxGap          time.Duration                  // cap on the duration one sample represents (time mode)
+	periodStart        time.Time                      // first parsed timestamp
+	periodEnd          time.Time                      // last parsed timestamp
+	quality            pdustats.QualityCounts         // data-quality counters from loading
+	format             string                         // FormatCSV or FormatJSON
+	summary            *SummaryOptions                // optional coincident per-rack summary
+	profiles           bool                           // also write daily and hourly profile CSVs
+	store              string                         // optional history database to record results in
+	anomalies          *AnomalyOptions                // optional anomaly detection over the raw series
 }
 
+// Average modes for the "avg" statistics
+const (
+	AverageSample = "sample" // plain mean of samples
+	AverageTime   = "time"   // mean weighted by the duration each sample represents
+)
+
 // NewDataProcessor creates a new processor instance
 func NewDataProcessor() *DataProcessor {
 	return &DataProcessor{
-		data: make(map[string][]float64),
+		data:    make(map[string][]Sample),
+		avgMode: AverageSample,
+		format:  FormatCSV,
 	}
 }
 
 // LoadInputFile loads data from input files like A1.xlsx, B1.xlsx
+// If the export contains several PDUs, only the columns of dp.pduName (or the first PDU
+// when unset) are loaded; use ExportPDUNames and LoadRows to process the others.
 func (dp *DataProcessor) LoadInputFile(filename string) error {
-	f, err := excelize.OpenFile(filename)
-	if err != nil {
-		return fmt.Errorf("failed to open input file %s: %v", filename, err)
-	}
-	defer f.Close()
-
-	// Get the first sheet
-	sheets := f.GetSheetList()
-	if len(sheets) == 0 {
-		return fmt.Errorf("no sheets found in %s", filename)
-	}
-
-	rows, err := f.GetRows(sheets[0])
+	rows, err := readExportRows(filename)
 	if err != nil {
-		return fmt.Errorf("failed to get rows from %s: %v", filename, err)
+		return err
 	}
+	return dp.LoadRows(filename, rows)
+}
 
+// LoadRows loads the samples of one PDU from the rows of an export
+func (dp *DataProcessor) LoadRows(filename string, rows [][]string) error {
 	if len(rows) < 2 {
 		return fmt.Errorf("insufficient data in %s", filename)
 	}
@@ -77,6 +100,7 @@ func (dp *DataProcessor) LoadInputFile(filename string) error {
 	// Parse headers to identify columns and PDU name
 	headers := rows[0]
 	columnMap := make(map[string]int) // key: "Q1_l1", value: column index
+	skippedColumns := 0               // columns belonging to other PDUs
 
 	for i, header := range headers {
 		if i == 0 {
@@ -98,64 +122,189 @@ func (dp *DataProcessor) LoadInputFile(filename string) error {
 		rackName := parts[1]            // "Q1"
 		lineType := parts[len(parts)-1] // "l1"
 
-		// Set PDU name (should be consistent across all columns)
+		// Set PDU name from the first column, then keep only that PDU's columns
 		if dp.pduName == "" {
 			dp.pduName = pduName
 		}
+		if pduName != dp.pduName {
+			skippedColumns++
+			continue
+		}
 
 		key := fmt.Sprintf("%s_%s", rackName, lineType)
 		columnMap[key] = i
 	}
 
 	// Process data rows
+	var timestamps []time.Time
 	for rowIndex := 1; rowIndex < len(rows); rowIndex++ {
 		row := rows[rowIndex]
+		if len(row) == 0 {
+			continue
+		}
+
+		dp.quality.Rows++
+		timestamp, err := parseTimestamp(row[0])
+		if err != nil {
+			dp.unparsedTimestamps++
+		} else {
+			timestamps = append(timestamps, timestamp)
+			if dp.periodStart.IsZero() || timestamp.Before(dp.periodStart) {
+				dp.periodStart = timestamp
+			}
+			if timestamp.After(dp.periodEnd) {
+				dp.periodEnd = timestamp
+			}
+		}
 
 		for key, colIndex := range columnMap {
 			if colIndex >= len(row) {
+				dp.quality.EmptyCells++
 				continue
 			}
 
 			cellValue := strings.TrimSpace(row[colIndex])
 			if cellValue == "" {
+				dp.quality.EmptyCells++
 				continue
 			}
 
 			value, err := strconv.ParseFloat(cellValue, 64)
 			if err != nil {
+				dp.quality.InvalidCells++
 				continue // Skip invalid values
 			}
+			if value == 0 {
+				dp.quality.ZeroSamples++
+			}
 
-			dp.data[key] = append(dp.data[key], value)
+			dp.data[key] = append(dp.data[key], Sample{Time: timestamp, Value: value})
 		}
 	}
 
+	dp.interval = DetectInterval(timestamps)
+	dp.quality.Columns = len(columnMap)
+	dp.quality.UnparsedTimestamps = dp.unparsedTimestamps
+
+	if len(columnMap) == 0 {
+		return fmt.Errorf("no columns for PDU %s in %s", dp.pduName, filename)
+	}
+
 	fmt.Printf("Loaded data from %s for PDU %s: %d columns processed\n", filename, dp.pduName, len(columnMap))
+	if skippedColumns > 0 {
+		fmt.Printf("⚠️  %d columns of other PDUs in %s were not merged into %s\n", skippedColumns, filename, dp.pduName)
+	}
+	fmt.Printf("Detected sampling interval: %s\n", formatInterval(dp.interval))
+	if dp.unparsedTimestamps > 0 {
+		fmt.Printf("⚠️  %d rows have unparseable timestamps\n", dp.unparsedTimestamps)
+	}
 	return nil
 }
 
 // CalculateStatistics calculates min, max, and average for a slice of values
+// Zero readings are treated as "no data" and excluded; Count is 0 when nothing remains.
 func (dp *DataProcessor) CalculateStatistics(values []float64) Statistics {
-	if len(values) == 0 {
-		return Statistics{0, 0, 0}
-	}
-
-	min := values[0]
-	max := values[0]
+	var stats Statistics
 	sum := 0.0
 
 	for _, val := range values {
-		if val < min {
-			min = val
+		if val == 0 {
+			continue
+		}
+		if stats.Count == 0 || val < stats.Min {
+			stats.Min = val
 		}
-		if val > max {
-			max = val
+		if stats.Count == 0 || val > stats.Max {
+			stats.Max = val
 		}
 		sum += val
+		stats.Count++
+	}
+
+	if stats.Count > 0 {
+		stats.Avg = sum / float64(stats.Count)
+		stats.P95 = percentile(values, 95)
+	}
+	return stats
+}
+
+// percentile returns the nearest-rank percentile of the non-zero values
+func percentile(values []float64, p float64) float64 {
+	var sorted []float64
+	for _, val := range values {
+		if val != 0 {
+			sorted = append(sorted, val)
+		}
+	}
+	if len(sorted) == 0 {
+		return 0
+	}
+	sort.Float64s(sorted)
+
+	rank := int(math.Ceil(p / 100 * float64(len(sorted))))
+	if rank < 1 {
+		rank = 1
+	}
+	return sorted[rank-1]
+}
+
+// CalculateTimeWeightedStatistics calculates min, max, and a time-weighted average.
+// Each sample is weighted by the time until the next sample, capped at maxGap so
+// outages don't stretch a single reading; the last sample gets the sampling interval.
+// Zero readings are excluded as in CalculateStatistics.
+func (dp *DataProcessor) CalculateTimeWeightedStatistics(samples []Sample, maxGap time.Duration) Statistics {
+	stats := dp.CalculateStatistics(sampleValues(samples))
+	if stats.Count == 0 {
+		return stats
 	}
 
-	avg := sum / float64(len(values))
-	return Statistics{Min: min, Max: max, Avg: avg}
+	sorted := make([]Sample, len(samples))
+	copy(sorted, samples)
+	sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].Time.Before(sorted[j].Time) })
+
+	weightedSum := 0.0
+	totalWeight := 0.0
+	for i, s := range sorted {
+		if s.Time.IsZero() {
+			return stats // No usable timestamps, keep the sample mean
+		}
+
+		weight := dp.sampleInterval()
+		if i+1 < len(sorted) {
+			weight = sorted[i+1].Time.Sub(s.Time)
+		}
+		if maxGap > 0 && weight > maxGap {
+			weight = maxGap
+		}
+		if s.Value == 0 || weight <= 0 {
+			continue
+		}
+
+		weightedSum += s.Value * weight.Seconds()
+		totalWeight += weight.Seconds()
+	}
+
+	if totalWeight > 0 {
+		stats.Avg = weightedSum / totalWeight
+	}
+	return stats
+}
+
+// seriesStatistics calculates the statistics for one series using the configured average mode
+func (dp *DataProcessor) seriesStatistics(key string) Statistics {
+	return dp.sampleStatistics(dp.data[key])
+}
+
+// sampleStatistics calculates the statistics of samples using the configured average mode
+func (dp *DataProcessor) sampleStatistics(samples []Sample) Statistics {
+	if dp.avgMode == AverageTime {
+		maxGap := dp.avgMaxGap
+		if maxGap == 0 {
+			maxGap = 2 * dp.sampleInterval() // Default: tolerate one missed sample
+		}
+		return dp.CalculateTimeWeightedStatistics(samples, maxGap)
+	}
+	return dp.CalculateStatistics(sampleValues(samples))
 }
 
 // GenerateOutput creates the output CSV file in the exact format expected
@@ -200,29 +349,78 @@ func (dp *DataProcessor) GenerateOutput(outputFile string) error {
 			rackName := fmt.Sprintf("Q%d", i)
 			key := fmt.Sprintf("%s_%s", rackName, lineType)
 
-			values, exists := dp.data[key]
+			// Unpopulated and idle racks have no meaningful statistics
+			if dp.RackState(rackName) != inventory.RackActive {
+				row = append(row, noDataLabel)
+				continue
+			}
+
+			stats := dp.seriesStatistics(key)
+			if stats.Count == 0 {
+				row = append(row, noDataLabel)
+				continue
+			}
+
 			var value float64
+			switch statType {
+			case "min":
+				value = stats.Min
+			case "avg":
+				value = stats.Avg
+			case "max":
+				value = stats.Max
+			}
+
+			row = append(row, fmt.Sprintf("%.3f", value))
+		}
 
-			if exists && len(values) > 0 {
-				stats := dp.CalculateStatistics(values)
+		if err := writer.Write(row); err != nil {
+			return fmt.Errorf("failed to write data row: %v", err)
+		}
+	}
+
+	// Coincident per-rack summary, followed by a row naming its rule
+	if dp.summary != nil {
+		for _, statType := range []string{"min", "avg", "max"} {
+			row := []string{"summary " + statType}
+			for i := 1; i <= 18; i++ {
+				stats := dp.rackSummary(fmt.Sprintf("Q%d", i))
+				if stats.Count == 0 {
+					row = append(row, noDataLabel)
+					continue
+				}
+
+				value := stats.Min
 				switch statType {
-				case "min":
-					value = stats.Min
 				case "avg":
 					value = stats.Avg
 				case "max":
 					value = stats.Max
 				}
+				row = append(row, fmt.Sprintf("%.3f", value))
+			}
+			if err := writer.Write(row); err != nil {
+				return fmt.Errorf("failed to write summary row: %v", err)
 			}
-
-			row = append(row, fmt.Sprintf("%.3f", value))
 		}
 
-		if err := writer.Write(row); err != nil {
-			return fmt.Errorf("failed to write data row: %v", err)
+		ruleRow := make([]string, len(header))
+		ruleRow[0] = "summary"
+		ruleRow[1] = dp.summary.Rule
+		ruleRow[2] = dp.summary.Unit()
+		if err := writer.Write(ruleRow); err != nil {
+			return fmt.Errorf("failed to write summary rule row: %v", err)
 		}
 	}
 
+	// Embed the PDU name so the filler doesn't depend on the filename
+	pduRow := make([]string, len(header))
+	pduRow[0] = "pdu"
+	pduRow[1] = dp.pduName
+	if err := writer.Write(pduRow); err != nil {
+		return fmt.Errorf("failed to write PDU name row: %v", err)
+	}
+
 	fmt.Printf("Output written to %s\n", outputFile)
 	return nil
 }
@@ -234,33 +432,223 @@ func (dp *DataProcessor) ProcessFile(inputFile, outputFile string) error {
 		return fmt.Errorf("error loading input file: %v", err)
 	}
 
+	return dp.ProcessLoaded(inputFile, outputFile)
+}
+
+// ProcessLoaded computes statistics for already loaded samples and writes the output
+func (dp *DataProcessor) ProcessLoaded(inputFile, outputFile string) error {
+	// Align series onto a common grid if requested
+	if dp.resample != nil {
+		if err := dp.ResampleAll(*dp.resample); err != nil {
+			return fmt.Errorf("error resampling: %v", err)
+		}
+	}
+
+	// Classify racks before computing statistics
+	dp.DetectRackStates()
+
+	fmt.Printf("Average mode: %s\n", dp.avgMode)
+	if dp.summary != nil {
+		if dp.unparsedTimestamps > 0 {
+			return fmt.Errorf("cannot align phases for the %s summary: %d rows have unparseable timestamps",
+				dp.summary.Rule, dp.unparsedTimestamps)
+		}
+		fmt.Printf("Rack summary: %s of phases per instant (%s)\n", dp.summary.Rule, dp.summary.Unit())
+	}
+
 	// Generate output
-	if err := dp.GenerateOutput(outputFile); err != nil {
+	if dp.format == FormatJSON {
+		if err := dp.GenerateJSONOutput(inputFile, outputFile); err != nil {
+			return fmt.Errorf("error generating output: %v", err)
+		}
+	} else if err := dp.GenerateOutput(outputFile); err != nil {
 		return fmt.Errorf("error generating output: %v", err)
 	}
 
+	if err := dp.generateProfiles(outputFile); err != nil {
+		return err
+	}
+	if err := dp.generateAnomalies(outputFile); err != nil {
+		return err
+	}
+	return dp.recordHistory(inputFile)
+}
+
+// generateProfiles writes the profile CSVs when requested
+func (dp *DataProcessor) generateProfiles(outputFile string) error {
+	if !dp.profiles {
+		return nil
+	}
+	if err := dp.GenerateProfiles(outputFile); err != nil {
+		return fmt.Errorf("error generating profiles: %v", err)
+	}
+	return nil
+}
+
+// generateAnomalies writes the anomaly CSV when requested
+func (dp *DataProcessor) generateAnomalies(outputFile string) error {
+	if dp.anomalies == nil {
+		return nil
+	}
+	if err := dp.GenerateAnomalies(outputFile); err != nil {
+		return fmt.Errorf("error detecting anomalies: %v", err)
+	}
 	return nil
 }
 
 func main() {
 	// Check command line arguments
 	if len(os.Args) < 2 {
-		fmt.Printf("Usage: %s <input_file> [output_file]\n", os.Args[0])
-		fmt.Printf("Example: %s A1.xlsx total_a1.csv\n", os.Args[0])
-		fmt.Printf("Example: %s B1.xlsx total_b1.csv\n", os.Args[0])
+		fmt.Printf("Usage: %s <input_file> [output_file] [options]\n", os.Args[0])
+		fmt.Printf("\nOptions:\n")
+		fmt.Printf("  -o, --output <file>      Output file (default: total_<input>.csv or .json)\n")
+		fmt.Printf("  -f, --format <fmt>       Output format: csv or json (default: csv)\n")
+		fmt.Printf("  -i, --inventory <file>   Rack inventory CSV (pdu,rack,state) overriding detection\n")
+		fmt.Printf("  -r, --resample <dur>     Resample every series onto a fixed grid, e.g. 15m\n")
+		fmt.Printf("      --agg <method>       Resample aggregation: mean, last, max (default: mean)\n")
+		fmt.Printf("      --max-gap <slots>    Max consecutive empty slots to interpolate (default: 1)\n")
+		fmt.Printf("      --avg <mode>         Average mode: sample or time (time-weighted) (default: sample)\n")
+		fmt.Printf("      --avg-cap <dur>      Max duration one sample represents in time mode (default: 2x interval)\n")
+		fmt.Printf("      --summary <rule>     Per-rack summary from time-aligned phases: mean, sum, max-phase, kw\n")
+		fmt.Printf("      --voltage <V>        Phase-to-neutral voltage for --summary kw (default: 230)\n")
+		fmt.Printf("      --pf <factor>        Power factor for --summary kw (default: 1.0)\n")
+		fmt.Printf("      --profiles           Also write <output>_daily.csv and <output>_profile.csv\n")
+		fmt.Printf("      --store <db>         Record the results in a history database (e.g. pdu-history.db)\n")
+		fmt.Printf("      --anomalies          Also write <output>_anomalies.csv (spikes, level shifts, outages)\n")
+		fmt.Printf("      --anomaly-window <d> Rolling window for --anomalies (default: 24h)\n")
+		fmt.Printf("      --anomaly-z <k>      Robust z-score flagged by --anomalies (default: 5)\n")
+		fmt.Printf("\nExamples:\n")
+		fmt.Printf("  %s A1.xlsx total_a1.csv\n", os.Args[0])
+		fmt.Printf("  %s B1.xlsx total_b1.csv\n", os.Args[0])
+		fmt.Printf("  %s B3.xlsx -i inventory.csv\n", os.Args[0])
+		fmt.Printf("  %s C2.xlsx --resample 15m --agg max\n", os.Args[0])
+		fmt.Printf("  %s C2.xlsx --avg time --avg-cap 30m\n", os.Args[0])
+		fmt.Printf("  %s C2.xlsx --format json\n", os.Args[0])
+		fmt.Printf("  %s C2.xlsx --profiles\n", os.Args[0])
+		fmt.Printf("  %s C2.xlsx --summary kw --voltage 230 --pf 0.95\n", os.Args[0])
+		fmt.Printf("  %s C2.xlsx --store pdu-history.db\n", os.Args[0])
+		fmt.Printf("  %s C2.xlsx --anomalies --anomaly-window 12h\n", os.Args[0])
 		os.Exit(1)
 	}
 
+	// Parse arguments
 	inputFile := os.Args[1]
+	outputFile := ""
+	inventoryFile := ""
+	resampleInterval := ""
+	resampleOpts := ResampleOptions{Aggregation: AggregateMean, MaxGap: 1}
+	avgMode := AverageSample
+	avgCap := ""
+	format := FormatCSV
+	summaryOpts := SummaryOptions{Voltage: 230, PowerFactor: 1}
+	profiles := false
+	store := ""
+	anomalies := false
+	anomalyWindow := "24h"
+	anomalyOpts := AnomalyOptions{Threshold: 5}
+
+	for i := 2; i < len(os.Args); i++ {
+		arg := os.Args[i]
+		switch arg {
+		case "-o", "--output":
+			if i+1 < len(os.Args) {
+				outputFile = os.Args[i+1]
+				i++ // Skip next argument
+			}
+		case "-i", "--inventory":
+			if i+1 < len(os.Args) {
+				inventoryFile = os.Args[i+1]
+				i++ // Skip next argument
+			}
+		case "-r", "--resample":
+			if i+1 < len(os.Args) {
+				resampleInterval = os.Args[i+1]
+				i++ // Skip next argument
+			}
+		case "--agg":
+			if i+1 < len(os.Args) {
+				resampleOpts.Aggregation = strings.ToLower(os.Args[i+1])
+				i++ // Skip next argument
+			}
+		case "--max-gap":
+			if i+1 < len(os.Args) {
+				gap, err := strconv.Atoi(os.Args[i+1])
+				if err != nil {
+					log.Fatalf("Invalid --max-gap value %q", os.Args[i+1])
+				}
+				resampleOpts.MaxGap = gap
+				i++ // Skip next argument
+			}
+		case "-f", "--format":
+			if i+1 < len(os.Args) {
+				format = strings.ToLower(os.Args[i+1])
+				i++ // Skip next argument
+			}
+		case "--avg":
+			if i+1 < len(os.Args) {
+				avgMode = strings.ToLower(os.Args[i+1])
+				i++ // Skip next argument
+			}
+		case "--avg-cap":
+			if i+1 < len(os.Args) {
+				avgCap = os.Args[i+1]
+				i++ // Skip next argument
+			}
+		case "--profiles":
+			profiles = true
+		case "--anomalies":
+			anomalies = true
+		case "--anomaly-window":
+			if i+1 < len(os.Args) {
+				anomalyWindow = os.Args[i+1]
+				i++ // Skip next argument
+			}
+		case "--anomaly-z":
+			if i+1 < len(os.Args) {
+				value, err := strconv.ParseFloat(os.Args[i+1], 64)
+				if err != nil {
+					log.Fatalf("Invalid --anomaly-z value %q", os.Args[i+1])
+				}
+				anomalyOpts.Threshold = value
+				i++ // Skip next argument
+			}
+		case "--store":
+			if i+1 < len(os.Args) {
+				store = os.Args[i+1]
+				i++ // Skip next argument
+			}
+		case "--summary":
+			if i+1 < len(os.Args) {
+				summaryOpts.Rule = strings.ToLower(os.Args[i+1])
+				i++ // Skip next argument
+			}
+		case "--voltage", "--pf":
+			if i+1 < len(os.Args) {
+				value, err := strconv.ParseFloat(os.Args[i+1], 64)
+				if err != nil {
+					log.Fatalf("Invalid %s value %q", arg, os.Args[i+1])
+				}
+				if arg == "--voltage" {
+					summaryOpts.Voltage = value
+				} else {
+					summaryOpts.PowerFactor = value
+				}
+				i++ // Skip next argument
+			}
+		default:
+			// Assume it's a positional output file for backward compatibility
+			if i == 2 && !strings.HasPrefix(arg, "-") {
+				outputFile = arg
+			}
+		}
+	}
 
 	// Generate default output filename based on input
-	outputFile := "result.csv"
-	if len(os.Args) > 2 {
-		outputFile = os.Args[2]
-	} else {
+	explicitOutput := outputFile != ""
+	if outputFile == "" {
 		// Auto-generate filename: A1.xlsx -> total_a1.csv
 		inputName := strings.TrimSuffix(inputFile, ".xlsx")
-		outputFile = fmt.Sprintf("total_%s.csv", strings.ToLower(inputName))
+		outputFile = fmt.Sprintf("total_%s.%s", strings.ToLower(inputName), format)
 	}
 
 	// Validate input file exists
@@ -268,14 +656,97 @@ func main() {
 		log.Fatalf("File %s not found", inputFile)
 	}
 
-	// Create processor and run
-	processor := NewDataProcessor()
-	if err := processor.ProcessFile(inputFile, outputFile); err != nil {
+	// Configure a processor with the shared options
+	base := NewDataProcessor()
+	if inventoryFile != "" {
+		inv, err := inventory.Load(inventoryFile)
+		if err != nil {
+			log.Fatalf("Loading inventory failed: %v", err)
+		}
+		base.inventory = inv
+	}
+	if resampleInterval != "" {
+		interval, err := time.ParseDuration(resampleInterval)
+		if err != nil {
+			log.Fatalf("Invalid --resample interval %q: %v", resampleInterval, err)
+		}
+		resampleOpts.Interval = interval
+		if err := resampleOpts.Validate(); err != nil {
+			log.Fatalf("Invalid resample options: %v", err)
+		}
+		base.resample = &resampleOpts
+	}
+	if avgMode != AverageSample && avgMode != AverageTime {
+		log.Fatalf("Invalid --avg mode %q (use sample or time)", avgMode)
+	}
+	base.avgMode = avgMode
+	if format != FormatCSV && format != FormatJSON {
+		log.Fatalf("Invalid --format %q (use csv or json)", format)
+	}
+	base.format = format
+	if avgCap != "" {
+		maxGap, err := time.ParseDuration(avgCap)
+		if err != nil || maxGap <= 0 {
+			log.Fatalf("Invalid --avg-cap duration %q", avgCap)
+		}
+		base.avgMaxGap = maxGap
+	}
+
+	if summaryOpts.Rule != "" {
+		if err := summaryOpts.Validate(); err != nil {
+			log.Fatalf("Invalid --summary options: %v", err)
+		}
+		base.summary = &summaryOpts
+	}
+
+	base.profiles = profiles
+	base.store = store
+
+	if anomalies {
+		window, err := time.ParseDuration(anomalyWindow)
+		if err != nil {
+			log.Fatalf("Invalid --anomaly-window %q: %v", anomalyWindow, err)
+		}
+		anomalyOpts.Window = window
+		if err := anomalyOpts.Validate(); err != nil {
+			log.Fatalf("Invalid anomaly options: %v", err)
+		}
+		base.anomalies = &anomalyOpts
+	}
+
+	// Read the export once and process every PDU it contains
+	rows, err := readExportRows(inputFile)
+	if err != nil {
 		log.Fatalf("Processing failed: %v", err)
 	}
+	pduNames := ExportPDUNames(rows)
+	if len(pduNames) == 0 {
+		log.Fatalf("Processing failed: no PDU columns found in %s", inputFile)
+	}
+	if len(pduNames) > 1 {
+		fmt.Printf("Detected %d PDUs in %s: %s - writing one output per PDU\n",
+			len(pduNames), inputFile, strings.Join(pduNames, ", "))
+	}
+
+	var outputs []string
+	for _, pduName := range pduNames {
+		pduOutput := outputFile
+		if len(pduNames) > 1 {
+			pduOutput = perPDUOutputFile(outputFile, explicitOutput, pduName, format)
+		}
+
+		processor := base.ForPDU(pduName)
+		if err := processor.LoadRows(inputFile, rows); err != nil {
+			log.Fatalf("Processing failed: error loading input file: %v", err)
+		}
+		if err := processor.ProcessLoaded(inputFile, pduOutput); err != nil {
+			log.Fatalf("Processing failed: %v", err)
+		}
+		outputs = append(outputs, pduOutput)
+	}
 
 	fmt.Printf("Processing completed successfully!\n")
 	fmt.Printf("Input: %s\n", inputFile)
-	fmt.Printf("Output: %s\n", outputFile)
-	fmt.Printf("PDU processed: %s\n", processor.pduName)
+	fmt.Printf("Output: %s\n", strings.Join(outputs, ", "))
+	fmt.Printf("PDU processed: %s\n", strings.Join(pduNames, ", "))
 }
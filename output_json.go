@@ -0,0 +1,99 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/reski-rukmantiyo/bdx-parser/pkg/inventory"
+	"github.com/reski-rukmantiyo/bdx-parser/pkg/pdustats"
+)
+
+// Output formats supported by the parser
+const (
+	FormatCSV  = "csv"
+	FormatJSON = "json"
+)
+
+// BuildResult collects the processed statistics into a pdustats.Result
+func (dp *DataProcessor) BuildResult(sourceFile string) *pdustats.Result {
+	result := pdustats.NewResult(dp.pduName)
+	result.SourceFile = sourceFile
+	result.IntervalSec = int64(dp.interval / time.Second)
+	result.AverageMode = dp.avgMode
+	result.Quality = dp.quality
+	if !dp.periodStart.IsZero() {
+		result.Period = pdustats.Period{
+			Start: dp.periodStart.Format(time.RFC3339),
+			End:   dp.periodEnd.Format(time.RFC3339),
+		}
+	}
+
+	if dp.summary != nil {
+		result.Summary = &pdustats.SummaryRule{Rule: dp.summary.Rule, Unit: dp.summary.Unit()}
+		if dp.summary.Rule == SummaryKW {
+			result.Summary.Voltage = dp.summary.Voltage
+			result.Summary.PowerFactor = dp.summary.PowerFactor
+		}
+	}
+
+	for i := 1; i <= 18; i++ {
+		rackName := fmt.Sprintf("Q%d", i)
+		state := dp.RackState(rackName)
+		rack := pdustats.Rack{
+			Rack:   rackName,
+			State:  state.String(),
+			Phases: make(map[string]pdustats.Phase),
+		}
+
+		for _, lineType := range pdustats.Phases {
+			var phase pdustats.Phase
+			if state == inventory.RackActive {
+				stats := dp.seriesStatistics(fmt.Sprintf("%s_%s", rackName, lineType))
+				phase.Samples = stats.Count
+				if stats.Count > 0 {
+					phase.Min = roundedValue(stats.Min)
+					phase.Avg = roundedValue(stats.Avg)
+					phase.Max = roundedValue(stats.Max)
+					phase.P95 = roundedValue(stats.P95)
+				}
+			}
+			rack.Phases[lineType] = phase
+		}
+
+		if dp.summary != nil {
+			var summary pdustats.Phase
+			stats := dp.rackSummary(rackName)
+			summary.Samples = stats.Count
+			if stats.Count > 0 {
+				summary.Min = roundedValue(stats.Min)
+				summary.Avg = roundedValue(stats.Avg)
+				summary.Max = roundedValue(stats.Max)
+				summary.P95 = roundedValue(stats.P95)
+			}
+			rack.Summary = &summary
+		}
+
+		result.Racks = append(result.Racks, rack)
+	}
+
+	result.DailyPeaks = dp.DailyPeaks()
+
+	return result
+}
+
+// roundedValue rounds a statistic to the 3 decimals used in the CSV output
+func roundedValue(v float64) *float64 {
+	rounded := math.Round(v*1000) / 1000
+	return &rounded
+}
+
+// GenerateJSONOutput writes the statistics as a versioned pdustats JSON document
+func (dp *DataProcessor) GenerateJSONOutput(sourceFile, outputFile string) error {
+	if err := pdustats.Write(outputFile, dp.BuildResult(sourceFile)); err != nil {
+		return err
+	}
+
+	fmt.Printf("Output written to %s\n", outputFile)
+	return nil
+}
@@ -0,0 +1,207 @@
+package history
+
+import (
+	"fmt"
+	"math"
+	"time"
+)
+
+// Forecast methods
+const (
+	MethodLinear   = "linear"         // least-squares trend over all months
+	MethodSeasonal = "seasonal-naive" // same month of the previous year
+)
+
+// seasonLength is the number of months in one seasonal cycle
+const seasonLength = 12
+
+// z95 is the two-sided 95% normal quantile
+const z95 = 1.96
+
+// t95 holds the two-sided 95% Student t quantiles for 1-30 degrees of freedom
+var t95 = []float64{
+	12.706, 4.303, 3.182, 2.776, 2.571, 2.447, 2.365, 2.306, 2.262, 2.228,
+	2.201, 2.179, 2.160, 2.145, 2.131, 2.120, 2.110, 2.101, 2.093, 2.086,
+	2.080, 2.074, 2.069, 2.064, 2.060, 2.056, 2.052, 2.048, 2.045, 2.042,
+}
+
+// Point is one monthly observation
+type Point struct {
+	Month string // YYYY-MM
+	Value float64
+}
+
+// Projection is one forecast month with its 95% prediction interval
+type Projection struct {
+	Month string
+	Value float64
+	Lower float64
+	Upper float64
+}
+
+// Forecast is a fitted model and its projections
+type Forecast struct {
+	Method      string
+	Months      int     // observations used
+	Slope       float64 // change per month (linear only)
+	Projections []Projection
+}
+
+// monthIndex returns the number of months since year 0
+func monthIndex(month string) (int, error) {
+	t, err := time.Parse(MonthLayout, month)
+	if err != nil {
+		return 0, fmt.Errorf("invalid month %q", month)
+	}
+	return t.Year()*12 + int(t.Month()) - 1, nil
+}
+
+// monthName is the inverse of monthIndex
+func monthName(index int) string {
+	return time.Date(index/12, time.Month(index%12+1), 1, 0, 0, 0, 0, time.UTC).Format(MonthLayout)
+}
+
+// tQuantile returns the two-sided 95% t quantile for df degrees of freedom
+func tQuantile(df int) float64 {
+	if df < 1 {
+		return math.Inf(1)
+	}
+	if df <= len(t95) {
+		return t95[df-1]
+	}
+	return z95
+}
+
+// FitForecast fits the method to points (in month order, gaps allowed) and
+// projects horizon months past the last observation
+func FitForecast(method string, points []Point, horizon int) (*Forecast, error) {
+	x := make([]int, len(points))
+	for i, p := range points {
+		index, err := monthIndex(p.Month)
+		if err != nil {
+			return nil, err
+		}
+		if i > 0 && index <= x[i-1] {
+			return nil, fmt.Errorf("months must be in ascending order")
+		}
+		x[i] = index
+	}
+
+	switch method {
+	case MethodLinear:
+		return fitLinear(points, x, horizon)
+	case MethodSeasonal:
+		return fitSeasonal(points, x, horizon)
+	}
+	return nil, fmt.Errorf("unknown forecast method %q", method)
+}
+
+// fitLinear fits value = a + b*month by least squares. The bounds are the
+// classical prediction interval, which widens away from the observed months.
+func fitLinear(points []Point, x []int, horizon int) (*Forecast, error) {
+	n := len(points)
+	if n < 3 {
+		return nil, fmt.Errorf("linear trend needs at least 3 months, have %d", n)
+	}
+
+	var meanX, meanY float64
+	for i, p := range points {
+		meanX += float64(x[i])
+		meanY += p.Value
+	}
+	meanX /= float64(n)
+	meanY /= float64(n)
+
+	var sxx, sxy float64
+	for i, p := range points {
+		dx := float64(x[i]) - meanX
+		sxx += dx * dx
+		sxy += dx * (p.Value - meanY)
+	}
+	slope := sxy / sxx
+	intercept := meanY - slope*meanX
+
+	var sse float64
+	for i, p := range points {
+		r := p.Value - (intercept + slope*float64(x[i]))
+		sse += r * r
+	}
+	s := math.Sqrt(sse / float64(n-2))
+	t := tQuantile(n - 2)
+
+	f := &Forecast{Method: MethodLinear, Months: n, Slope: slope}
+	last := x[n-1]
+	for h := 1; h <= horizon; h++ {
+		xh := float64(last + h)
+		value := intercept + slope*xh
+		margin := t * s * math.Sqrt(1+1/float64(n)+(xh-meanX)*(xh-meanX)/sxx)
+		f.Projections = append(f.Projections, Projection{
+			Month: monthName(last + h), Value: value, Lower: value - margin, Upper: value + margin,
+		})
+	}
+	return f, nil
+}
+
+// fitSeasonal repeats the value of the same month one cycle earlier. The bounds
+// use the spread of the year-over-year changes, widening with each further cycle.
+func fitSeasonal(points []Point, x []int, horizon int) (*Forecast, error) {
+	byMonth := make(map[int]float64, len(points))
+	for i, p := range points {
+		byMonth[x[i]] = p.Value
+	}
+
+	var sse float64
+	residuals := 0
+	for i, p := range points {
+		if previous, ok := byMonth[x[i]-seasonLength]; ok {
+			r := p.Value - previous
+			sse += r * r
+			residuals++
+		}
+	}
+	if residuals == 0 {
+		return nil, fmt.Errorf("seasonal-naive needs the same month in two consecutive years")
+	}
+	sigma := math.Sqrt(sse / float64(residuals))
+
+	f := &Forecast{Method: MethodSeasonal, Months: len(points)}
+	last := x[len(x)-1]
+	for h := 1; h <= horizon; h++ {
+		cycles := (h-1)/seasonLength + 1
+		value, ok := byMonth[last+h-cycles*seasonLength]
+		if !ok {
+			continue // No observation for this month last year
+		}
+		margin := z95 * sigma * math.Sqrt(float64(cycles))
+		f.Projections = append(f.Projections, Projection{
+			Month: monthName(last + h), Value: value, Lower: value - margin, Upper: value + margin,
+		})
+	}
+	return f, nil
+}
+
+// Crossing returns the first projected month whose forecast reaches capacity,
+// and the earliest and latest such month within the 95% bounds (upper bound
+// and lower bound reaching it). Empty strings mean not within the horizon.
+func (f *Forecast) Crossing(capacity float64) (month, earliest, latest string) {
+	for _, p := range f.Projections {
+		if earliest == "" && p.Upper >= capacity {
+			earliest = p.Month
+		}
+		if month == "" && p.Value >= capacity {
+			month = p.Month
+		}
+		if latest == "" && p.Lower >= capacity {
+			latest = p.Month
+		}
+	}
+	return month, earliest, latest
+}
+
+// Horizon returns the last projected month, or "" if there are no projections
+func (f *Forecast) Horizon() string {
+	if len(f.Projections) == 0 {
+		return ""
+	}
+	return f.Projections[len(f.Projections)-1].Month
+}
@@ -0,0 +1,238 @@
+// Package history stores parsed PDU statistics in a local SQLite database so trends
+// and year-to-date figures can be reported without re-parsing the exports.
+package history
+
+import (
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/reski-rukmantiyo/bdx-parser/pkg/pdustats"
+
+	_ "modernc.org/sqlite" // pure Go driver, no cgo
+)
+
+// DefaultPath is the database used when none is given
+const DefaultPath = "pdu-history.db"
+
+// MonthLayout is the month format used for periods and filters
+const MonthLayout = "2006-01"
+
+// Metrics lists the stored statistics; p95 only comes from JSON results
+var Metrics = []string{"min", "avg", "max", "p95"}
+
+// schema creates the results table; one row per statistic of one source file
+const schema = `
+CREATE TABLE IF NOT EXISTS results (
+	pdu          TEXT    NOT NULL,
+	rack         TEXT    NOT NULL,
+	phase        TEXT    NOT NULL, -- l1, l2, l3 or summary
+	metric       TEXT    NOT NULL, -- min, avg, max, p95
+	month        TEXT    NOT NULL, -- YYYY-MM of the period start
+	period_start TEXT    NOT NULL, -- RFC 3339
+	period_end   TEXT    NOT NULL,
+	value        REAL    NOT NULL,
+	samples      INTEGER NOT NULL,
+	source_file  TEXT    NOT NULL,
+	source_hash  TEXT    NOT NULL, -- SHA-256 of the source file
+	recorded_at  TEXT    NOT NULL,
+	PRIMARY KEY (pdu, rack, phase, metric, period_start, period_end, source_hash)
+);
+CREATE INDEX IF NOT EXISTS results_lookup ON results (pdu, rack, phase, metric, month);
+`
+
+// Entry is one stored statistic
+type Entry struct {
+	PDU         string
+	Rack        string
+	Phase       string
+	Metric      string
+	Month       string
+	PeriodStart string
+	PeriodEnd   string
+	Value       float64
+	Samples     int
+	SourceFile  string
+	SourceHash  string
+}
+
+// Store is an open history database
+type Store struct {
+	db *sql.DB
+}
+
+// Open opens (and if needed creates) a history database
+func Open(path string) (*Store, error) {
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open %s: %v", path, err)
+	}
+	if _, err := db.Exec(schema); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to initialise %s: %v", path, err)
+	}
+	return &Store{db: db}, nil
+}
+
+// Close closes the database
+func (s *Store) Close() error {
+	return s.db.Close()
+}
+
+// Record stores entries in one transaction. Entries already stored for the same
+// source hash and period are replaced, so importing a file twice is harmless.
+func (s *Store) Record(entries []Entry) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+
+	stmt, err := tx.Prepare(`INSERT OR REPLACE INTO results
+		(pdu, rack, phase, metric, month, period_start, period_end, value, samples, source_file, source_hash, recorded_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	defer stmt.Close()
+
+	now := time.Now().UTC().Format(time.RFC3339)
+	for _, e := range entries {
+		if _, err := stmt.Exec(e.PDU, e.Rack, e.Phase, e.Metric, e.Month, e.PeriodStart, e.PeriodEnd,
+			e.Value, e.Samples, e.SourceFile, e.SourceHash, now); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("failed to store %s %s %s %s: %v", e.PDU, e.Rack, e.Phase, e.Metric, err)
+		}
+	}
+	return tx.Commit()
+}
+
+// Filter selects entries; empty fields match everything, months are inclusive
+type Filter struct {
+	PDU       string
+	Rack      string
+	Phase     string
+	Metric    string
+	FromMonth string
+	ToMonth   string
+}
+
+// Query returns the matching entries ordered by PDU, rack, phase, metric and month.
+// When several source files cover the same month, the most recently recorded wins
+// (INSERT OR REPLACE gives every write a new, higher rowid).
+func (s *Store) Query(f Filter) ([]Entry, error) {
+	var where []string
+	var args []interface{}
+	for _, cond := range []struct {
+		column, op, value string
+	}{
+		{"pdu", "=", strings.ToUpper(f.PDU)},
+		{"rack", "=", strings.ToUpper(f.Rack)},
+		{"phase", "=", strings.ToLower(f.Phase)},
+		{"metric", "=", strings.ToLower(f.Metric)},
+		{"month", ">=", f.FromMonth},
+		{"month", "<=", f.ToMonth},
+	} {
+		if cond.value != "" {
+			where = append(where, fmt.Sprintf("%s %s ?", cond.column, cond.op))
+			args = append(args, cond.value)
+		}
+	}
+
+	query := `SELECT pdu, rack, phase, metric, month, period_start, period_end, value, samples, source_file, source_hash
+		FROM results r`
+	conditions := append(where, `rowid = (SELECT MAX(rowid) FROM results l
+		WHERE l.pdu = r.pdu AND l.rack = r.rack AND l.phase = r.phase AND l.metric = r.metric AND l.month = r.month)`)
+	query += " WHERE " + strings.Join(conditions, " AND ")
+	query += " ORDER BY pdu, CAST(SUBSTR(rack, 2) AS INTEGER), rack, phase, metric, month"
+
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("query failed: %v", err)
+	}
+	defer rows.Close()
+
+	var entries []Entry
+	for rows.Next() {
+		var e Entry
+		if err := rows.Scan(&e.PDU, &e.Rack, &e.Phase, &e.Metric, &e.Month, &e.PeriodStart, &e.PeriodEnd,
+			&e.Value, &e.Samples, &e.SourceFile, &e.SourceHash); err != nil {
+			return nil, err
+		}
+		entries = append(entries, e)
+	}
+	return entries, rows.Err()
+}
+
+// FileHash returns the SHA-256 of a file as hex
+func FileHash(filename string) (string, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, file); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// MonthPeriod returns the RFC 3339 start and end of a YYYY-MM month
+func MonthPeriod(month string) (string, string, error) {
+	start, err := time.Parse(MonthLayout, month)
+	if err != nil {
+		return "", "", fmt.Errorf("invalid month %q (use YYYY-MM)", month)
+	}
+	end := start.AddDate(0, 1, 0).Add(-time.Second)
+	return start.Format(time.RFC3339), end.Format(time.RFC3339), nil
+}
+
+// FromResult converts a parser result into entries. Results without a period
+// (e.g. unparseable timestamps) need month to place them.
+func FromResult(r *pdustats.Result, sourceHash, month string) ([]Entry, error) {
+	start, end := r.Period.Start, r.Period.End
+	if month != "" {
+		var err error
+		if start, end, err = MonthPeriod(month); err != nil {
+			return nil, err
+		}
+	}
+	if start == "" {
+		return nil, fmt.Errorf("%s has no period; give the month explicitly", r.SourceFile)
+	}
+	t, err := time.Parse(time.RFC3339, start)
+	if err != nil {
+		return nil, fmt.Errorf("invalid period start %q: %v", start, err)
+	}
+
+	base := Entry{PDU: strings.ToUpper(r.PDU), Month: t.Format(MonthLayout), PeriodStart: start, PeriodEnd: end,
+		SourceFile: r.SourceFile, SourceHash: sourceHash}
+
+	var entries []Entry
+	add := func(rack, phaseName string, phase pdustats.Phase) {
+		for _, metric := range Metrics {
+			if value := phase.Value(metric); value != nil {
+				e := base
+				e.Rack, e.Phase, e.Metric = rack, phaseName, metric
+				e.Value, e.Samples = *value, phase.Samples
+				entries = append(entries, e)
+			}
+		}
+	}
+	for _, rack := range r.Racks {
+		for _, phaseName := range pdustats.Phases {
+			add(rack.Rack, phaseName, rack.Phases[phaseName])
+		}
+		if rack.Summary != nil {
+			add(rack.Rack, "summary", *rack.Summary)
+		}
+	}
+	return entries, nil
+}
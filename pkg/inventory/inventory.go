@@ -0,0 +1,153 @@
+// Package inventory describes which racks exist on each PDU and whether they are in use.
+package inventory
+
+import (
+	"encoding/csv"
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// RackState describes whether a rack is populated and drawing load
+type RackState int
+
+const (
+	RackUnknown     RackState = iota
+	RackUnpopulated           // no equipment installed, no columns in the export
+	RackIdle                  // columns present but every sample is zero
+	RackActive                // at least one non-zero sample
+)
+
+// String returns the lowercase name used in inventory files and console output
+func (s RackState) String() string {
+	switch s {
+	case RackUnpopulated:
+		return "unpopulated"
+	case RackIdle:
+		return "idle"
+	case RackActive:
+		return "active"
+	default:
+		return "unknown"
+	}
+}
+
+// ParseRackState converts an inventory state name into a RackState
+func ParseRackState(s string) (RackState, error) {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "unpopulated", "empty":
+		return RackUnpopulated, nil
+	case "idle":
+		return RackIdle, nil
+	case "active":
+		return RackActive, nil
+	}
+	return RackUnknown, fmt.Errorf("unknown rack state %q", s)
+}
+
+// Inventory holds the declared state of racks, keyed by "PDU_Rack" (e.g. "A1_Q7")
+type Inventory map[string]RackState
+
+// Load reads a CSV inventory with columns: pdu, rack, state
+// A header row starting with "pdu" is skipped.
+func Load(filename string) (Inventory, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open inventory file: %v", err)
+	}
+	defer file.Close()
+
+	reader := csv.NewReader(file)
+	reader.FieldsPerRecord = -1
+	records, err := reader.ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read inventory CSV: %v", err)
+	}
+
+	inv := make(Inventory)
+	for i, record := range records {
+		if len(record) < 3 {
+			continue
+		}
+		if i == 0 && strings.EqualFold(strings.TrimSpace(record[0]), "pdu") {
+			continue // Skip header
+		}
+
+		state, err := ParseRackState(record[2])
+		if err != nil {
+			return nil, fmt.Errorf("inventory line %d: %v", i+1, err)
+		}
+
+		pduName := strings.ToUpper(strings.TrimSpace(record[0]))
+		rackName := strings.ToUpper(strings.TrimSpace(record[1]))
+		inv[key(pduName, rackName)] = state
+	}
+
+	fmt.Printf("Loaded inventory from %s: %d racks declared\n", filename, len(inv))
+	return inv, nil
+}
+
+// key builds the map key for a PDU and rack
+func key(pduName, rackName string) string {
+	return fmt.Sprintf("%s_%s", strings.ToUpper(pduName), strings.ToUpper(rackName))
+}
+
+// State returns the declared state of a rack, if the inventory lists it
+func (inv Inventory) State(pduName, rackName string) (RackState, bool) {
+	if inv == nil {
+		return RackUnknown, false
+	}
+	state, ok := inv[key(pduName, rackName)]
+	return state, ok
+}
+
+// PDUs returns the PDU names in the inventory in natural order (A2 before A10)
+func (inv Inventory) PDUs() []string {
+	seen := make(map[string]bool)
+	var names []string
+	for k := range inv {
+		pduName := k[:strings.LastIndex(k, "_")]
+		if !seen[pduName] {
+			seen[pduName] = true
+			names = append(names, pduName)
+		}
+	}
+	sort.Slice(names, func(i, j int) bool { return NaturalLess(names[i], names[j]) })
+	return names
+}
+
+// Racks returns the rack names declared for a PDU in natural order (Q2 before Q10)
+func (inv Inventory) Racks(pduName string) []string {
+	prefix := strings.ToUpper(pduName) + "_"
+	var racks []string
+	for k := range inv {
+		if strings.HasPrefix(k, prefix) {
+			racks = append(racks, strings.TrimPrefix(k, prefix))
+		}
+	}
+	sort.Slice(racks, func(i, j int) bool { return NaturalLess(racks[i], racks[j]) })
+	return racks
+}
+
+// digitRuns splits names like "A10" into text and number parts
+var digitRuns = regexp.MustCompile(`\d+|\D+`)
+
+// NaturalLess compares names so embedded numbers sort numerically ("A2" < "A10")
+func NaturalLess(a, b string) bool {
+	pa, pb := digitRuns.FindAllString(a, -1), digitRuns.FindAllString(b, -1)
+	for i := 0; i < len(pa) && i < len(pb); i++ {
+		if pa[i] == pb[i] {
+			continue
+		}
+		na, errA := strconv.Atoi(pa[i])
+		nb, errB := strconv.Atoi(pb[i])
+		if errA == nil && errB == nil {
+			return na < nb
+		}
+		return pa[i] < pb[i]
+	}
+	return len(pa) < len(pb)
+}
@@ -0,0 +1,153 @@
+// Package pdustats defines the versioned intermediate file written by the PDU
+// parser and read by the monthly filler.
+package pdustats
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+)
+
+// Format identifies a PDU statistics file
+const Format = "pdu-stats"
+
+// SchemaVersion is the schema version written and accepted by this build.
+// Bump it whenever the layout changes incompatibly.
+const SchemaVersion = 1
+
+// Phases and Metrics list the series and statistics every rack carries
+var (
+	Phases  = []string{"l1", "l2", "l3"}
+	Metrics = []string{"min", "avg", "max"}
+)
+
+// Result is the self-describing statistics document for one PDU
+type Result struct {
+	Format        string        `json:"format"`
+	SchemaVersion int           `json:"schema_version"`
+	PDU           string        `json:"pdu"`
+	SourceFile    string        `json:"source_file"`
+	Period        Period        `json:"period"`
+	IntervalSec   int64         `json:"interval_seconds"`
+	AverageMode   string        `json:"average_mode"`
+	Measurement   string        `json:"measurement"`
+	Phases        []string      `json:"phases"`
+	Metrics       []string      `json:"metrics"`
+	Summary       *SummaryRule  `json:"summary,omitempty"`
+	Racks         []Rack        `json:"racks"`
+	DailyPeaks    []DailyPeak   `json:"daily_peaks,omitempty"`
+	Quality       QualityCounts `json:"quality"`
+}
+
+// Period is the time span covered by the parsed samples (RFC 3339, empty if unknown)
+type Period struct {
+	Start string `json:"start"`
+	End   string `json:"end"`
+}
+
+// SummaryRule describes how the per-rack summary was computed from the phases
+type SummaryRule struct {
+	Rule        string  `json:"rule"` // mean, sum, max-phase or kw
+	Unit        string  `json:"unit"` // "A" or "kW"
+	Voltage     float64 `json:"voltage,omitempty"`
+	PowerFactor float64 `json:"power_factor,omitempty"`
+}
+
+// Rack holds the state and per-phase statistics of one rack
+type Rack struct {
+	Rack    string           `json:"rack"`
+	State   string           `json:"state"`
+	Phases  map[string]Phase `json:"phases"`            // key: "l1", "l2", "l3"
+	Summary *Phase           `json:"summary,omitempty"` // coincident summary, if requested
+}
+
+// Phase holds the statistics of one phase; values are nil when there is no data
+type Phase struct {
+	Min     *float64 `json:"min"`
+	Avg     *float64 `json:"avg"`
+	Max     *float64 `json:"max"`
+	P95     *float64 `json:"p95,omitempty"` // 95th percentile; absent in files from older parsers
+	Samples int      `json:"samples"`
+}
+
+// DailyPeak is the highest reading of each phase across the PDU's active racks on one day
+type DailyPeak struct {
+	Date   string              `json:"date"`   // YYYY-MM-DD
+	Phases map[string]*float64 `json:"phases"` // key: "l1", "l2", "l3"; nil when no data
+}
+
+// QualityCounts are data-quality counters collected while loading the input
+type QualityCounts struct {
+	Rows               int `json:"rows"`
+	Columns            int `json:"columns"`
+	EmptyCells         int `json:"empty_cells"`
+	InvalidCells       int `json:"invalid_cells"`
+	ZeroSamples        int `json:"zero_samples"`
+	UnparsedTimestamps int `json:"unparsed_timestamps"`
+}
+
+// NewResult creates a Result stamped with the current format and schema version
+func NewResult(pduName string) *Result {
+	return &Result{
+		Format:        Format,
+		SchemaVersion: SchemaVersion,
+		PDU:           pduName,
+		Measurement:   "current",
+		Phases:        Phases,
+		Metrics:       Metrics,
+	}
+}
+
+// Value returns the named metric ("min", "avg", "max", "p95") of a phase
+func (p Phase) Value(metric string) *float64 {
+	switch metric {
+	case "min":
+		return p.Min
+	case "avg":
+		return p.Avg
+	case "max":
+		return p.Max
+	case "p95":
+		return p.P95
+	}
+	return nil
+}
+
+// Write stores the result as indented JSON
+func Write(filename string, r *Result) error {
+	data, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode JSON: %v", err)
+	}
+
+	if err := os.WriteFile(filename, append(data, '\n'), 0644); err != nil {
+		return fmt.Errorf("failed to write %s: %v", filename, err)
+	}
+	return nil
+}
+
+// Read loads a statistics file and checks its format and schema version
+func Read(filename string) (*Result, error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %v", filename, err)
+	}
+
+	var r Result
+	if err := json.Unmarshal(data, &r); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %v", filename, err)
+	}
+
+	if r.Format != Format {
+		return nil, fmt.Errorf("%s is not a %s file (format %q)", filename, Format, r.Format)
+	}
+	if r.SchemaVersion != SchemaVersion {
+		return nil, fmt.Errorf("%s has schema version %d, but this build supports version %d; re-run pdu-parser or upgrade monthly-filler",
+			filename, r.SchemaVersion, SchemaVersion)
+	}
+	if r.PDU == "" {
+		return nil, fmt.Errorf("%s does not name a PDU", filename)
+	}
+
+	return &r, nil
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// backupTimeFormat is the timestamp embedded in backup file names
+const backupTimeFormat = "20060102-150405"
+
+// writeFileAtomic writes through a temp file in the target directory, syncs it and
+// renames it over filename, so a crash or full disk never leaves a partial report
+func writeFileAtomic(filename string, write func(io.Writer) error) error {
+	dir := filepath.Dir(filename)
+	tmp, err := os.CreateTemp(dir, "."+filepath.Base(filename)+".tmp-*")
+	if err != nil {
+		return fmt.Errorf("failed to create temp file: %v", err)
+	}
+	tmpName := tmp.Name()
+	defer os.Remove(tmpName) // No-op once renamed
+
+	if err := write(tmp); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to sync %s: %v", tmpName, err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to close %s: %v", tmpName, err)
+	}
+
+	// Keep the permissions of the file being replaced
+	if info, err := os.Stat(filename); err == nil {
+		os.Chmod(tmpName, info.Mode().Perm())
+	} else {
+		os.Chmod(tmpName, 0644)
+	}
+
+	if err := os.Rename(tmpName, filename); err != nil {
+		return fmt.Errorf("failed to replace %s: %v", filename, err)
+	}
+
+	// Persist the rename itself; not supported on every platform
+	if d, err := os.Open(dir); err == nil {
+		d.Sync()
+		d.Close()
+	}
+	return nil
+}
+
+// backupFile copies filename to a timestamped backup next to it and removes the
+// oldest backups beyond keep. It does nothing if keep is 0 or filename doesn't exist.
+func backupFile(filename string, keep int) (string, error) {
+	if keep <= 0 {
+		return "", nil
+	}
+	src, err := os.Open(filename)
+	if os.IsNotExist(err) {
+		return "", nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to open %s for backup: %v", filename, err)
+	}
+	defer src.Close()
+
+	backupName := fmt.Sprintf("%s.%s.bak", filename, time.Now().Format(backupTimeFormat))
+	err = writeFileAtomic(backupName, func(w io.Writer) error {
+		_, err := io.Copy(w, src)
+		return err
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to back up %s: %v", filename, err)
+	}
+
+	if err := pruneBackups(filename, keep); err != nil {
+		return backupName, err
+	}
+	return backupName, nil
+}
+
+// pruneBackups removes all but the newest keep backups of filename
+func pruneBackups(filename string, keep int) error {
+	matches, err := filepath.Glob(filename + ".*.bak")
+	if err != nil {
+		return err
+	}
+
+	// Timestamps sort lexically; keep only names with a valid timestamp
+	var backups []string
+	for _, match := range matches {
+		stamp := strings.TrimSuffix(strings.TrimPrefix(match, filename+"."), ".bak")
+		if _, err := time.Parse(backupTimeFormat, stamp); err == nil {
+			backups = append(backups, match)
+		}
+	}
+	sort.Strings(backups)
+
+	for i := 0; i < len(backups)-keep; i++ {
+		if err := os.Remove(backups[i]); err != nil {
+			return fmt.Errorf("failed to remove old backup %s: %v", backups[i], err)
+		}
+	}
+	return nil
+}
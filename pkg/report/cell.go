@@ -0,0 +1,193 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/xuri/excelize/v2"
+)
+
+// CellKind is the type of a template cell
+type CellKind int
+
+const (
+	CellEmpty CellKind = iota
+	CellString
+	CellNumber
+	CellDate
+	CellFormula
+)
+
+// String returns the kind name used in messages
+func (k CellKind) String() string {
+	switch k {
+	case CellString:
+		return "string"
+	case CellNumber:
+		return "number"
+	case CellDate:
+		return "date"
+	case CellFormula:
+		return "formula"
+	default:
+		return "empty"
+	}
+}
+
+// Cell is one template cell. Text is what the template shows and is written back
+// unchanged; only cells the filler writes (Written) get the filler's formatting.
+type Cell struct {
+	Kind    CellKind
+	Text    string  // displayed text, e.g. "1", "01/06/2025", "N/A"
+	Value   float64 // numeric value of number and date cells (Excel serial for dates)
+	Formula string  // formula without the leading "=", for formula cells
+	Written bool    // set by the filler
+}
+
+// dateLayouts are the text date formats recognised in CSV templates
+var dateLayouts = []string{"2006-01-02", "02/01/2006", "2/1/2006", "01-02-06", "Jan-06", "January 2006"}
+
+// textCell classifies a cell read as text, e.g. from a CSV template
+func textCell(text string) Cell {
+	trimmed := strings.TrimSpace(text)
+	if trimmed == "" {
+		return Cell{Kind: CellEmpty, Text: text}
+	}
+	if v, err := strconv.ParseFloat(trimmed, 64); err == nil {
+		return Cell{Kind: CellNumber, Text: text, Value: v}
+	}
+	for _, layout := range dateLayouts {
+		if t, err := time.Parse(layout, trimmed); err == nil {
+			return Cell{Kind: CellDate, Text: text, Value: t.Sub(excelEpoch).Hours() / 24}
+		}
+	}
+	return Cell{Kind: CellString, Text: text}
+}
+
+// metricCell is a statistic written by the filler, marking missing data as N/A
+func metricCell(v float64) Cell {
+	if math.IsNaN(v) {
+		return Cell{Kind: CellString, Text: noDataLabel, Written: true}
+	}
+	return Cell{Kind: CellNumber, Text: fmt.Sprintf("%.3f", v), Value: v, Written: true}
+}
+
+// Number returns the value of a number cell, or of a formula cell whose (cached)
+// result is a number
+func (c Cell) Number() (float64, bool) {
+	switch c.Kind {
+	case CellNumber:
+		return c.Value, true
+	case CellFormula:
+		if _, err := strconv.ParseFloat(strings.TrimSpace(c.Text), 64); err == nil {
+			return c.Value, true
+		}
+	}
+	return 0, false
+}
+
+// sameValue reports whether two cells show the same value; numbers are compared
+// at the 3 decimals the filler writes, so "1.65" read back equals "1.650", and
+// cells with the same formula are the same (changes show up in their inputs)
+func sameValue(a, b Cell) bool {
+	if a.Text == b.Text {
+		return true
+	}
+	if a.Kind == CellFormula && b.Kind == CellFormula && a.Formula == b.Formula {
+		return true
+	}
+	x, okA := a.Number()
+	y, okB := b.Number()
+	return okA && okB && math.Round(x*1000) == math.Round(y*1000)
+}
+
+// IsEmpty reports whether the cell holds no value
+func (c Cell) IsEmpty() bool {
+	return c.Kind == CellEmpty || (c.Kind != CellFormula && strings.TrimSpace(c.Text) == "")
+}
+
+// holdsStatistic reports whether the cell holds a statistic; "N/A" left by a run
+// without data for the rack counts as empty
+func (c Cell) holdsStatistic() bool {
+	return !c.IsEmpty() && !strings.EqualFold(strings.TrimSpace(c.Text), noDataLabel)
+}
+
+// excelEpoch is day zero for Excel serial date numbers
+var excelEpoch = time.Date(1899, 12, 30, 0, 0, 0, 0, time.UTC)
+
+// loadExcelCells reads the first sheet of a workbook as typed cells, keeping the
+// displayed text of every cell together with its formula or raw numeric value
+func (mf *MonthlyFiller) loadExcelCells(filename string) ([][]Cell, error) {
+	f, err := excelize.OpenFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	sheets := f.GetSheetList()
+	if len(sheets) == 0 {
+		return nil, fmt.Errorf("no sheets found")
+	}
+	sheet := sheets[0]
+
+	rows, err := f.GetRows(sheet)
+	if err != nil {
+		return nil, err
+	}
+
+	dateStyles := make(map[int]bool)
+	cells := make([][]Cell, len(rows))
+	for i, row := range rows {
+		cells[i] = make([]Cell, len(row))
+		for j, text := range row {
+			name, _ := excelize.CoordinatesToCellName(j+1, i+1)
+			cell := Cell{Kind: CellEmpty, Text: text}
+
+			if formula, err := f.GetCellFormula(sheet, name); err == nil && formula != "" {
+				cell.Kind = CellFormula
+				cell.Formula = strings.TrimPrefix(formula, "=")
+				if v, err := strconv.ParseFloat(strings.TrimSpace(text), 64); err == nil {
+					cell.Value = v // cached result
+				}
+			} else if text != "" {
+				cell.Kind = CellString
+				if cellType, _ := f.GetCellType(sheet, name); cellType == excelize.CellTypeNumber ||
+					cellType == excelize.CellTypeUnset || cellType == excelize.CellTypeDate {
+					raw, _ := f.GetCellValue(sheet, name, excelize.Options{RawCellValue: true})
+					if v, err := strconv.ParseFloat(raw, 64); err == nil {
+						cell.Kind = CellNumber
+						cell.Value = v
+						if style, err := f.GetCellStyle(sheet, name); err == nil && mf.isDateStyle(f, style, dateStyles) {
+							cell.Kind = CellDate
+						}
+					}
+				}
+			}
+			cells[i][j] = cell
+		}
+	}
+	return cells, nil
+}
+
+// isDateStyle reports whether a cell style uses a date or time number format
+func (mf *MonthlyFiller) isDateStyle(f *excelize.File, styleID int, cache map[int]bool) bool {
+	if isDate, ok := cache[styleID]; ok {
+		return isDate
+	}
+
+	isDate := false
+	if style, err := f.GetStyle(styleID); err == nil {
+		switch {
+		case style.NumFmt >= 14 && style.NumFmt <= 22, style.NumFmt >= 45 && style.NumFmt <= 47:
+			isDate = true
+		case style.CustomNumFmt != nil:
+			format := strings.ToLower(*style.CustomNumFmt)
+			isDate = strings.ContainsAny(format, "dy") || strings.Contains(format, "h:mm")
+		}
+	}
+	cache[styleID] = isDate
+	return isDate
+}
@@ -0,0 +1,278 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/reski-rukmantiyo/bdx-parser/pkg/pdustats"
+	"github.com/xuri/excelize/v2"
+)
+
+// Sheets written by --charts
+const (
+	chartsSheet     = "Charts"
+	dailyPeaksSheet = "Daily Peaks"
+)
+
+// chartRows is the vertical distance between the charts of consecutive PDUs
+const chartRows = 18
+
+// Hidden bookkeeping columns of the Charts sheet: series names, and per chart slot
+// the PDU it shows and whether its daily chart was added
+const (
+	nameColumn   = "AA"
+	slotColumn   = "AB"
+	dailyColumn  = "AC"
+	dailyCharted = "daily"
+)
+
+// barSeries are the metric columns plotted per rack, with their legend names
+var barSeries = []struct {
+	Key  string
+	Name string
+}{
+	{"L1Max", "L1 Max"}, {"L2Max", "L2 Max"}, {"L3Max", "L3 Max"},
+	{"L1Avg", "L1 AVG"}, {"L2Avg", "L2 AVG"}, {"L3Avg", "L3 AVG"},
+}
+
+// quoteSheet quotes a sheet name for use in a cell reference
+func quoteSheet(name string) string {
+	return "'" + strings.ReplaceAll(name, "'", "''") + "'"
+}
+
+// absRange returns an absolute reference to column col, rows first..last (0-based)
+func absRange(sheet string, col, first, last int) string {
+	name, _ := excelize.ColumnNumberToName(col + 1)
+	return fmt.Sprintf("%s!$%s$%d:$%s$%d", quoteSheet(sheet), name, first+1, name, last+1)
+}
+
+// ensureSheet creates a sheet if the workbook doesn't have it yet
+func ensureSheet(f *excelize.File, sheet string) (bool, error) {
+	if index, _ := f.GetSheetIndex(sheet); index >= 0 {
+		return false, nil
+	}
+	if _, err := f.NewSheet(sheet); err != nil {
+		return false, fmt.Errorf("failed to create sheet %s: %v", sheet, err)
+	}
+	return true, nil
+}
+
+// writeDailyPeaks stores the daily peaks of the current PDU on the Daily Peaks sheet.
+// Every section owns three fixed columns and every day of the month one row, so the
+// charts of earlier runs keep pointing at the right cells. It returns the number of
+// days in the sheet's month, or 0 if the sheet doesn't exist.
+func (mf *MonthlyFiller) writeDailyPeaks(f *excelize.File) (int, error) {
+	var month time.Time
+	if index, _ := f.GetSheetIndex(dailyPeaksSheet); index >= 0 {
+		first, _ := f.GetCellValue(dailyPeaksSheet, "A2")
+		if t, err := time.Parse("2006-01-02", first); err == nil {
+			month = t
+		}
+	}
+	if month.IsZero() {
+		if len(mf.pduData.DailyPeaks) == 0 {
+			return 0, nil
+		}
+		t, err := time.Parse("2006-01-02", mf.pduData.DailyPeaks[0].Date)
+		if err != nil {
+			return 0, fmt.Errorf("invalid daily peak date %q", mf.pduData.DailyPeaks[0].Date)
+		}
+		month = time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.UTC)
+	}
+	days := month.AddDate(0, 1, -1).Day()
+
+	created, err := ensureSheet(f, dailyPeaksSheet)
+	if err != nil {
+		return 0, err
+	}
+	if created {
+		f.SetCellStr(dailyPeaksSheet, "A1", "Date")
+		for day := 0; day < days; day++ {
+			cell, _ := excelize.CoordinatesToCellName(1, day+2)
+			f.SetCellStr(dailyPeaksSheet, cell, month.AddDate(0, 0, day).Format("2006-01-02"))
+		}
+		for k, section := range mf.pduSections {
+			for p, phase := range pdustats.Phases {
+				cell, _ := excelize.CoordinatesToCellName(2+3*k+p, 1)
+				f.SetCellStr(dailyPeaksSheet, cell, fmt.Sprintf("%s %s", section.Name, strings.ToUpper(phase)))
+			}
+		}
+	}
+
+	if len(mf.pduData.DailyPeaks) == 0 {
+		return days, nil
+	}
+	k := mf.sectionIndex(mf.pduData.PDUName)
+	if k < 0 {
+		return days, nil
+	}
+
+	// Replace the current PDU's columns
+	for p := range pdustats.Phases {
+		for day := 0; day < days; day++ {
+			cell, _ := excelize.CoordinatesToCellName(2+3*k+p, day+2)
+			f.SetCellValue(dailyPeaksSheet, cell, nil)
+		}
+	}
+	outside := 0
+	for _, peak := range mf.pduData.DailyPeaks {
+		t, err := time.Parse("2006-01-02", peak.Date)
+		if err != nil || t.Year() != month.Year() || t.Month() != month.Month() {
+			outside++
+			continue
+		}
+		for p, phase := range pdustats.Phases {
+			if v := peak.Phases[phase]; v != nil {
+				cell, _ := excelize.CoordinatesToCellName(2+3*k+p, t.Day()+1)
+				f.SetCellFloat(dailyPeaksSheet, cell, *v, -1, 64)
+			}
+		}
+	}
+	if outside > 0 {
+		fmt.Printf("⚠️  %d daily peaks of PDU %s fall outside %s and were not charted\n",
+			outside, mf.pduData.PDUName, month.Format("January 2006"))
+	}
+	return days, nil
+}
+
+// sectionIndex returns the position of a PDU's section in the report, or -1
+func (mf *MonthlyFiller) sectionIndex(pduName string) int {
+	for k, section := range mf.pduSections {
+		if strings.EqualFold(section.Name, pduName) {
+			return k
+		}
+	}
+	return -1
+}
+
+// writeCharts adds, for every PDU section holding data, a bar chart of L1-L3 max and
+// average per rack and a line chart of its daily peaks to the Charts sheet. Charts
+// read the report cells directly, so each is added once and stays current.
+func (mf *MonthlyFiller) writeCharts(f *excelize.File, reportSheet string) error {
+	days, err := mf.writeDailyPeaks(f)
+	if err != nil {
+		return err
+	}
+
+	created, err := ensureSheet(f, chartsSheet)
+	if err != nil {
+		return err
+	}
+	if created {
+		for i, series := range barSeries {
+			f.SetCellStr(chartsSheet, fmt.Sprintf("%s%d", nameColumn, i+1), series.Name)
+		}
+		if err := f.SetColVisible(chartsSheet, nameColumn+":"+dailyColumn, false); err != nil {
+			return err
+		}
+	}
+
+	// Slots already used by earlier runs
+	slots := make(map[string]int)
+	used := 0
+	for ; ; used++ {
+		name, _ := f.GetCellValue(chartsSheet, fmt.Sprintf("%s%d", slotColumn, used+1))
+		if name == "" {
+			break
+		}
+		slots[name] = used
+	}
+
+	mapping := mf.layout.ColumnMapping()
+	added := 0
+	for k, section := range mf.pduSections {
+		first, last, ok := mf.sectionDataRows(section)
+		if !ok {
+			continue
+		}
+
+		slot, exists := slots[section.Name]
+		if !exists {
+			slot = used
+			used++
+			bar := &excelize.Chart{
+				Type:      excelize.Col,
+				Title:     []excelize.RichTextRun{{Text: fmt.Sprintf("PDU %s - current per rack (A)", section.Name)}},
+				Legend:    excelize.ChartLegend{Position: "bottom"},
+				Dimension: excelize.ChartDimension{Width: 640, Height: 320},
+				YAxis:     excelize.ChartAxis{MajorGridLines: true},
+			}
+			for i, series := range barSeries {
+				bar.Series = append(bar.Series, excelize.ChartSeries{
+					Name:       fmt.Sprintf("%s!$%s$%d", chartsSheet, nameColumn, i+1),
+					Categories: absRange(reportSheet, mf.layout.LabelColumn, first, last),
+					Values:     absRange(reportSheet, mapping[series.Key], first, last),
+				})
+			}
+			if err := f.AddChart(chartsSheet, fmt.Sprintf("A%d", 1+slot*chartRows), bar); err != nil {
+				return fmt.Errorf("failed to add chart for PDU %s: %v", section.Name, err)
+			}
+			f.SetCellStr(chartsSheet, fmt.Sprintf("%s%d", slotColumn, slot+1), section.Name)
+			added++
+		}
+
+		// Daily chart, once the PDU has daily peaks on the Daily Peaks sheet
+		marker, _ := f.GetCellValue(chartsSheet, fmt.Sprintf("%s%d", dailyColumn, slot+1))
+		if marker == dailyCharted || days == 0 || !mf.hasDailyPeaks(f, k, days) {
+			continue
+		}
+		line := &excelize.Chart{
+			Type:         excelize.Line,
+			Title:        []excelize.RichTextRun{{Text: fmt.Sprintf("PDU %s - daily peak per phase (A)", section.Name)}},
+			Legend:       excelize.ChartLegend{Position: "bottom"},
+			Dimension:    excelize.ChartDimension{Width: 640, Height: 320},
+			YAxis:        excelize.ChartAxis{MajorGridLines: true},
+			ShowBlanksAs: "gap",
+		}
+		for p := range pdustats.Phases {
+			header, _ := excelize.CoordinatesToCellName(2+3*k+p, 1, true)
+			line.Series = append(line.Series, excelize.ChartSeries{
+				Name:       fmt.Sprintf("%s!%s", quoteSheet(dailyPeaksSheet), header),
+				Categories: absRange(dailyPeaksSheet, 0, 1, days),
+				Values:     absRange(dailyPeaksSheet, 1+3*k+p, 1, days),
+			})
+		}
+		if err := f.AddChart(chartsSheet, fmt.Sprintf("L%d", 1+slot*chartRows), line); err != nil {
+			return fmt.Errorf("failed to add daily chart for PDU %s: %v", section.Name, err)
+		}
+		f.SetCellStr(chartsSheet, fmt.Sprintf("%s%d", dailyColumn, slot+1), dailyCharted)
+		added++
+	}
+
+	fmt.Printf("Charts: %d PDU sections on sheet %s (%d charts added)\n", used, chartsSheet, added)
+	return nil
+}
+
+// hasDailyPeaks reports whether section k has any value on the Daily Peaks sheet
+func (mf *MonthlyFiller) hasDailyPeaks(f *excelize.File, k, days int) bool {
+	for p := range pdustats.Phases {
+		for day := 0; day < days; day++ {
+			cell, _ := excelize.CoordinatesToCellName(2+3*k+p, day+2)
+			if value, _ := f.GetCellValue(dailyPeaksSheet, cell); value != "" {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// sectionDataRows returns the first and last rack row of a section if any of its
+// metric cells holds a number
+func (mf *MonthlyFiller) sectionDataRows(section PDUSection) (int, int, bool) {
+	first, last, hasData := -1, -1, false
+	for _, row := range section.RackRows {
+		if first < 0 || row < first {
+			first = row
+		}
+		if row > last {
+			last = row
+		}
+		for _, col := range mf.layout.ColumnMapping() {
+			if cellAt(mf.monthlyData[row], col).Kind == CellNumber {
+				hasData = true
+			}
+		}
+	}
+	return first, last, hasData
+}
@@ -0,0 +1,395 @@
+package main
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/reski-rukmantiyo/bdx-parser/pkg/inventory"
+	"github.com/xuri/excelize/v2"
+)
+
+// comparisonSheet is the sheet name of XLSX comparison output
+const comparisonSheet = "Comparison"
+
+// defaultGrowthThreshold flags racks whose peak grew by more than this percentage
+const defaultGrowthThreshold = 10.0
+
+// ReportValues holds the statistics of one month: PDU -> rack number -> column key -> value
+type ReportValues map[string]map[int]map[string]float64
+
+// set records a statistic, ignoring missing values
+func (v ReportValues) set(pduName string, rack int, key string, value float64) {
+	if math.IsNaN(value) {
+		return
+	}
+	pduName = strings.ToUpper(pduName)
+	if v[pduName] == nil {
+		v[pduName] = make(map[int]map[string]float64)
+	}
+	if v[pduName][rack] == nil {
+		v[pduName][rack] = make(map[string]float64)
+	}
+	v[pduName][rack][key] = value
+}
+
+// Comparison is one statistic of one rack in two months
+type Comparison struct {
+	PDU        string
+	Rack       int
+	Column     string  // layout column key, e.g. "L1Max"
+	Old, New   float64 // NaN when missing in that month
+	Delta      float64 // NaN unless both months have a value
+	Change     float64 // percent; NaN unless old is non-zero
+	PeakGrowth bool
+}
+
+// isPeakColumn reports whether a column holds a peak (max) statistic
+func isPeakColumn(key string) bool {
+	return strings.HasSuffix(key, "Max")
+}
+
+// isParserOutput reports whether a file is a parser output (JSON or positional CSV)
+// rather than a filled report
+func isParserOutput(filename string) bool {
+	if strings.EqualFold(filepath.Ext(filename), ".json") {
+		return true
+	}
+	if !strings.EqualFold(filepath.Ext(filename), ".csv") {
+		return false
+	}
+
+	file, err := os.Open(filename)
+	if err != nil {
+		return false
+	}
+	defer file.Close()
+	reader := csv.NewReader(file)
+	reader.FieldsPerRecord = -1
+	header, err := reader.Read()
+	return err == nil && len(header) > 0 && strings.TrimSpace(header[0]) == "Measurement Type"
+}
+
+// loadReportValues reads the statistics of a filled report or a parser output
+func loadReportValues(filename string, layout *TemplateLayout) (ReportValues, error) {
+	values := make(ReportValues)
+	filler := NewMonthlyFiller()
+	filler.layout = layout
+
+	if isParserOutput(filename) {
+		if err := filler.LoadPDUData(filename); err != nil {
+			return nil, err
+		}
+		d := &filler.pduData
+		for q := 0; q < 18; q++ {
+			for _, key := range layoutColumns[:9] {
+				measurement := strings.ToLower(key[:2] + " " + key[2:])
+				values.set(d.PDUName, q+1, key, d.Series(measurement)[q])
+			}
+			summaryMin, summaryAvg, summaryMax := filler.summaryValues(q)
+			values.set(d.PDUName, q+1, "SummaryMin", summaryMin)
+			values.set(d.PDUName, q+1, "SummaryAvg", summaryAvg)
+			values.set(d.PDUName, q+1, "SummaryMax", summaryMax)
+		}
+		return values, nil
+	}
+
+	if err := filler.LoadMonthlyTemplate(filename, false, ""); err != nil {
+		return nil, err
+	}
+	mapping := filler.layout.ColumnMapping()
+	for _, section := range filler.pduSections {
+		for rack, row := range section.RackRows {
+			for _, key := range layoutColumns {
+				cell := cellAt(filler.monthlyData[row], mapping[key])
+				switch {
+				case cell.Kind == CellNumber:
+					values.set(section.Name, rack, key, cell.Value)
+				case cell.Kind == CellFormula && strings.HasPrefix(key, "Summary"):
+					// --summary-formulas cells: recompute from the phase cells like Excel would
+					values.set(section.Name, rack, key, filler.summaryFromPhases(row, key))
+				}
+			}
+		}
+	}
+	return values, nil
+}
+
+// summaryFromPhases computes a summary column of a report row from its L1-L3 cells,
+// as summaryValues does for parser data; NaN when no phase holds a number
+func (mf *MonthlyFiller) summaryFromPhases(row int, key string) float64 {
+	mapping := mf.layout.ColumnMapping()
+	stat := strings.TrimPrefix(key, "Summary") // Min, Avg or Max
+
+	phases := make([]float64, 3)
+	for i := range phases {
+		phases[i] = math.NaN()
+		cell := cellAt(mf.monthlyData[row], mapping[fmt.Sprintf("L%d%s", i+1, stat)])
+		if v, ok := cell.Number(); ok {
+			phases[i] = v
+		}
+	}
+
+	switch stat {
+	case "Min":
+		return mf.minValue(phases...)
+	case "Max":
+		return mf.maxValue(phases...)
+	}
+	return mf.avgValue(phases...)
+}
+
+// CompareReports compares every statistic present in either month
+func CompareReports(oldValues, newValues ReportValues, threshold float64) []Comparison {
+	// Union of PDUs, racks and columns
+	racks := make(map[string]map[int]bool)
+	for _, values := range []ReportValues{oldValues, newValues} {
+		for pduName, byRack := range values {
+			if racks[pduName] == nil {
+				racks[pduName] = make(map[int]bool)
+			}
+			for rack := range byRack {
+				racks[pduName][rack] = true
+			}
+		}
+	}
+
+	pduNames := make([]string, 0, len(racks))
+	for pduName := range racks {
+		pduNames = append(pduNames, pduName)
+	}
+	sort.Slice(pduNames, func(i, j int) bool { return inventory.NaturalLess(pduNames[i], pduNames[j]) })
+
+	var comparisons []Comparison
+	for _, pduName := range pduNames {
+		rackNumbers := make([]int, 0, len(racks[pduName]))
+		for rack := range racks[pduName] {
+			rackNumbers = append(rackNumbers, rack)
+		}
+		sort.Ints(rackNumbers)
+
+		for _, rack := range rackNumbers {
+			for _, key := range layoutColumns {
+				oldValue, hasOld := oldValues[pduName][rack][key]
+				newValue, hasNew := newValues[pduName][rack][key]
+				if !hasOld && !hasNew {
+					continue
+				}
+
+				c := Comparison{PDU: pduName, Rack: rack, Column: key,
+					Old: math.NaN(), New: math.NaN(), Delta: math.NaN(), Change: math.NaN()}
+				if hasOld {
+					c.Old = oldValue
+				}
+				if hasNew {
+					c.New = newValue
+				}
+				if hasOld && hasNew {
+					c.Delta = newValue - oldValue
+					if oldValue != 0 {
+						c.Change = c.Delta / oldValue * 100
+					}
+				}
+				c.PeakGrowth = isPeakColumn(key) && !math.IsNaN(c.Change) && c.Change > threshold
+				comparisons = append(comparisons, c)
+			}
+		}
+	}
+	return comparisons
+}
+
+// comparisonHeader is the header row of comparison output
+var comparisonHeader = []string{"PDU", "Rack", "Statistic", "Old", "New", "Delta", "Change %", "Flag"}
+
+// record formats a comparison for CSV output
+func (c Comparison) record() []string {
+	format := func(v float64, pattern string) string {
+		if math.IsNaN(v) {
+			return ""
+		}
+		return fmt.Sprintf(pattern, v)
+	}
+
+	flag := ""
+	switch {
+	case c.PeakGrowth:
+		flag = "peak growth"
+	case math.IsNaN(c.Old):
+		flag = "new"
+	case math.IsNaN(c.New):
+		flag = "missing"
+	}
+
+	return []string{c.PDU, fmt.Sprintf("Q%d", c.Rack), columnTitles[c.Column],
+		format(c.Old, "%.3f"), format(c.New, "%.3f"), format(c.Delta, "%.3f"), format(c.Change, "%.1f"), flag}
+}
+
+// writeComparisonCSV writes comparisons as CSV
+func writeComparisonCSV(w io.Writer, comparisons []Comparison) error {
+	writer := csv.NewWriter(w)
+	if err := writer.Write(comparisonHeader); err != nil {
+		return err
+	}
+	for _, c := range comparisons {
+		if err := writer.Write(c.record()); err != nil {
+			return fmt.Errorf("failed to write CSV row: %v", err)
+		}
+	}
+	writer.Flush()
+	return writer.Error()
+}
+
+// writeComparisonXLSX writes comparisons as a workbook with peak growth rows highlighted
+func writeComparisonXLSX(w io.Writer, comparisons []Comparison, threshold float64) error {
+	f := excelize.NewFile()
+	defer f.Close()
+
+	if err := f.SetSheetName(f.GetSheetName(0), comparisonSheet); err != nil {
+		return fmt.Errorf("failed to name sheet: %v", err)
+	}
+
+	header, _ := f.NewStyle(&excelize.Style{Font: &excelize.Font{Bold: true},
+		Fill: excelize.Fill{Type: "pattern", Pattern: 1, Color: []string{"DDEBF7"}}})
+	growth, _ := f.NewStyle(&excelize.Style{Fill: excelize.Fill{Type: "pattern", Pattern: 1, Color: []string{DefaultThresholds().Colors.Alert}}})
+	number, _ := f.NewStyle(&excelize.Style{NumFmt: 2}) // 0.00
+
+	headerRow := toInterfaces(comparisonHeader)
+	if err := f.SetSheetRow(comparisonSheet, "A1", &headerRow); err != nil {
+		return err
+	}
+	f.SetCellStyle(comparisonSheet, "A1", "H1", header)
+
+	for i, c := range comparisons {
+		record := c.record()
+		row := []interface{}{record[0], record[1], record[2]}
+		for _, v := range []float64{c.Old, c.New, c.Delta, c.Change} {
+			if math.IsNaN(v) {
+				row = append(row, nil)
+			} else {
+				row = append(row, math.Round(v*1000)/1000)
+			}
+		}
+		row = append(row, record[7])
+
+		cell, _ := excelize.CoordinatesToCellName(1, i+2)
+		if err := f.SetSheetRow(comparisonSheet, cell, &row); err != nil {
+			return err
+		}
+		from, _ := excelize.CoordinatesToCellName(4, i+2)
+		to, _ := excelize.CoordinatesToCellName(7, i+2)
+		f.SetCellStyle(comparisonSheet, from, to, number)
+		if c.PeakGrowth {
+			first, _ := excelize.CoordinatesToCellName(1, i+2)
+			last, _ := excelize.CoordinatesToCellName(8, i+2)
+			f.SetCellStyle(comparisonSheet, first, last, growth)
+		}
+	}
+
+	f.SetColWidth(comparisonSheet, "C", "C", 18)
+	f.SetColWidth(comparisonSheet, "D", "H", 12)
+	f.SetPanes(comparisonSheet, &excelize.Panes{Freeze: true, YSplit: 1, TopLeftCell: "A2", ActivePane: "bottomLeft"})
+	f.SetCellStr(comparisonSheet, "J1", fmt.Sprintf("Highlighted: peak (max) grew by more than %g%%", threshold))
+	return f.Write(w)
+}
+
+// toInterfaces converts strings for SetSheetRow
+func toInterfaces(values []string) []interface{} {
+	result := make([]interface{}, len(values))
+	for i, v := range values {
+		result[i] = v
+	}
+	return result
+}
+
+// runCompare implements "compare" and returns the exit code
+func runCompare(args []string) int {
+	var files []string
+	outputFile := "comparison.csv"
+	threshold := defaultGrowthThreshold
+	layout := DefaultLayout()
+
+	for i := 0; i < len(args); i++ {
+		switch args[i] {
+		case "-o", "--output", "-g", "--growth", "-l", "--layout":
+			if i+1 >= len(args) {
+				fmt.Printf("Missing value for %s\n", args[i])
+				return 2
+			}
+			value := args[i+1]
+			switch args[i] {
+			case "-o", "--output":
+				outputFile = value
+			case "-g", "--growth":
+				parsed, err := strconv.ParseFloat(value, 64)
+				if err != nil || parsed < 0 {
+					fmt.Printf("Invalid threshold %q\n", value)
+					return 2
+				}
+				threshold = parsed
+			default:
+				loaded, err := LoadLayout(value)
+				if err != nil {
+					log.Printf("Loading layout failed: %v", err)
+					return 2
+				}
+				layout = loaded
+			}
+			i++ // Skip value
+		default:
+			files = append(files, args[i])
+		}
+	}
+
+	if len(files) != 2 {
+		fmt.Println("Usage: monthly-filler compare <old> <new> [-o comparison.csv|.xlsx] [-g growth%] [-l layout.json]")
+		fmt.Println("       <old> and <new> are filled reports (.csv/.xlsx) or parser outputs (total_xx.csv/.json)")
+		return 2
+	}
+
+	var months []ReportValues
+	for _, filename := range files {
+		values, err := loadReportValues(filename, layout)
+		if err != nil {
+			log.Printf("Loading %s failed: %v", filename, err)
+			return 1
+		}
+		months = append(months, values)
+	}
+
+	comparisons := CompareReports(months[0], months[1], threshold)
+
+	write := func(w io.Writer) error { return writeComparisonCSV(w, comparisons) }
+	if isXLSX(outputFile) {
+		write = func(w io.Writer) error { return writeComparisonXLSX(w, comparisons, threshold) }
+	}
+	if err := writeFileAtomic(outputFile, write); err != nil {
+		log.Printf("Writing comparison failed: %v", err)
+		return 1
+	}
+
+	// Racks whose peak grew beyond the threshold
+	var grown []string
+	seen := make(map[string]bool)
+	for _, c := range comparisons {
+		rack := fmt.Sprintf("%s Q%d", c.PDU, c.Rack)
+		if c.PeakGrowth && !seen[rack] {
+			seen[rack] = true
+			grown = append(grown, rack)
+		}
+	}
+
+	fmt.Printf("\n=== Compared %s -> %s: %d statistics written to %s ===\n", files[0], files[1], len(comparisons), outputFile)
+	if len(grown) == 0 {
+		fmt.Printf("✅ No rack peak grew by more than %g%%\n", threshold)
+		return 0
+	}
+	fmt.Printf("⚠️  %d racks with peak growth over %g%%: %s\n", len(grown), threshold, strings.Join(grown, ", "))
+	return 0
+}
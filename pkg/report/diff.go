@@ -0,0 +1,93 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+)
+
+// CellChange is one template cell that filling would change
+type CellChange struct {
+	PDU    string
+	Rack   string
+	Column string // layout column key, e.g. "L1Max"
+	Row    int
+	Col    int
+	Old    string
+	New    string
+}
+
+// snapshotSection copies the rows of a section so they can be compared after filling
+func (mf *MonthlyFiller) snapshotSection(section *PDUSection) map[int][]Cell {
+	snapshot := make(map[int][]Cell)
+	for _, row := range section.RackRows {
+		snapshot[row] = append([]Cell(nil), mf.monthlyData[row]...)
+	}
+	return snapshot
+}
+
+// DiffSection compares the metric cells of a section with a snapshot taken before filling
+func (mf *MonthlyFiller) DiffSection(section *PDUSection, before map[int][]Cell) []CellChange {
+	var changes []CellChange
+	mapping := mf.layout.ColumnMapping()
+
+	racks := make([]int, 0, len(section.RackRows))
+	for rack := range section.RackRows {
+		racks = append(racks, rack)
+	}
+	sort.Ints(racks)
+
+	for _, rack := range racks {
+		row := section.RackRows[rack]
+		for _, key := range layoutColumns {
+			col := mapping[key]
+			oldCell, newCell := cellAt(before[row], col), cellAt(mf.monthlyData[row], col)
+			if sameValue(oldCell, newCell) {
+				continue
+			}
+			oldValue, newValue := oldCell.Text, newCell.Text
+			changes = append(changes, CellChange{
+				PDU:    section.Name,
+				Rack:   fmt.Sprintf("Q%d", rack),
+				Column: key,
+				Row:    row,
+				Col:    col,
+				Old:    oldValue,
+				New:    newValue,
+			})
+		}
+	}
+	return changes
+}
+
+// cellAt returns the cell at col, or an empty cell if the row is shorter
+func cellAt(row []Cell, col int) Cell {
+	if col < len(row) {
+		return row[col]
+	}
+	return Cell{}
+}
+
+// PrintDiff prints every changed cell (verbose) or just the counts
+func PrintDiff(changes []CellChange, verbose bool) {
+	added, modified := 0, 0
+	for _, change := range changes {
+		if change.Old == "" {
+			added++
+		} else {
+			modified++
+		}
+	}
+
+	if verbose && len(changes) > 0 {
+		fmt.Printf("\n%-6s %-5s %-11s %12s    %s\n", "PDU", "Rack", "Column", "Old", "New")
+		for _, change := range changes {
+			oldValue := change.Old
+			if oldValue == "" {
+				oldValue = "(empty)"
+			}
+			fmt.Printf("%-6s %-5s %-11s %12s -> %s\n", change.PDU, change.Rack, change.Column, oldValue, change.New)
+		}
+	}
+
+	fmt.Printf("\nDiff: %d cells changed (%d filled, %d overwritten)\n", len(changes), added, modified)
+}
@@ -0,0 +1,32 @@
+package main
+
+// Policies for PDU sections that already contain data
+const (
+	OnExistingOverwrite = "overwrite" // replace every cell (previous behaviour)
+	OnExistingSkip      = "skip"      // leave the section and the output untouched
+	OnExistingFail      = "fail"      // stop with an error
+	OnExistingMerge     = "merge"     // only fill cells that are still empty
+)
+
+// validOnExisting reports whether a policy name is known
+func validOnExisting(policy string) bool {
+	switch policy {
+	case OnExistingOverwrite, OnExistingSkip, OnExistingFail, OnExistingMerge:
+		return true
+	}
+	return false
+}
+
+// filledCells counts the metric cells of a section that already hold a statistic,
+// checking every rack row and every metric column
+func (mf *MonthlyFiller) filledCells(section *PDUSection) int {
+	filled := 0
+	for _, row := range section.RackRows {
+		for _, col := range mf.layout.ColumnMapping() {
+			if cellAt(mf.monthlyData[row], col).holdsStatistic() {
+				filled++
+			}
+		}
+	}
+	return filled
+}
@@ -0,0 +1,185 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"strconv"
+
+	"github.com/reski-rukmantiyo/bdx-parser/pkg/history"
+)
+
+// defaultForecastHorizon is how many months past the last observation are projected
+const defaultForecastHorizon = 24
+
+// Forecast statuses, from most to least urgent
+const (
+	forecastOver     = "over capacity" // last observed month already reached capacity
+	forecastCrossing = "crossing"      // the forecast reaches capacity within the horizon
+	forecastPossible = "possible"      // only the upper 95% bound reaches capacity
+	forecastOK       = "ok"            // not even the upper bound reaches capacity
+	forecastNoData   = "insufficient"  // not enough months for the method
+)
+
+// CapacityForecast is the projection of one rack phase by one method
+type CapacityForecast struct {
+	PDU, Rack, Phase, Metric string
+	Method                   string
+	Months                   int
+	LastMonth                string
+	LastValue                float64
+	Slope                    float64 // per month, linear only
+	Capacity                 float64
+	Status                   string
+	Crossing                 string // projected month of reaching capacity
+	Earliest, Latest         string // 95% bounds of the crossing month
+	Horizon                  string // last projected month
+	Note                     string
+
+	fitted bool // the method could be fitted to the months available
+}
+
+// forecastCapacity fits every method to the monthly series of one rack phase
+func forecastCapacity(points []history.Point, base CapacityForecast, methods []string, horizon int) []CapacityForecast {
+	var forecasts []CapacityForecast
+	last := points[len(points)-1]
+	for _, method := range methods {
+		c := base
+		c.Method = method
+		c.Months = len(points)
+		c.LastMonth, c.LastValue = last.Month, last.Value
+
+		f, err := history.FitForecast(method, points, horizon)
+		if err != nil {
+			c.Note = err.Error()
+		} else {
+			c.fitted = true
+			c.Slope = f.Slope
+			c.Horizon = f.Horizon()
+			c.Crossing, c.Earliest, c.Latest = f.Crossing(c.Capacity)
+		}
+
+		switch {
+		case last.Value >= c.Capacity:
+			c.Status = forecastOver
+			c.Crossing, c.Earliest, c.Latest = last.Month, "", ""
+		case err != nil:
+			c.Status = forecastNoData
+		case c.Crossing != "":
+			c.Status = forecastCrossing
+		case c.Earliest != "":
+			c.Status = forecastPossible
+		default:
+			c.Status = forecastOK
+		}
+		forecasts = append(forecasts, c)
+	}
+	return forecasts
+}
+
+// record formats a forecast as a CSV row
+func (c CapacityForecast) record() []string {
+	slope := ""
+	if c.Method == history.MethodLinear && c.fitted {
+		slope = fmt.Sprintf("%.3f", c.Slope)
+	}
+	crossing, earliest, latest := c.Crossing, c.Earliest, c.Latest
+	if c.Status != forecastOver && c.Status != forecastNoData {
+		crossing, earliest, latest = orAfter(crossing, c.Horizon), orAfter(earliest, c.Horizon), orAfter(latest, c.Horizon)
+	}
+	return []string{c.PDU, c.Rack, c.Phase, c.Metric, c.Method, strconv.Itoa(c.Months),
+		c.LastMonth, formatHistoryValue(c.LastValue), slope, formatHistoryValue(c.Capacity),
+		c.Status, crossing, earliest, latest, c.Note}
+}
+
+// historyForecast projects monthly peaks per rack phase and reports when they reach capacity
+func historyForecast(store *history.Store, opts historyOptions) int {
+	capacity := opts.capacity
+	if capacity == 0 {
+		thresholds := DefaultThresholds()
+		if opts.thresholds != "" {
+			loaded, err := LoadThresholds(opts.thresholds)
+			if err != nil {
+				log.Printf("Loading thresholds failed: %v", err)
+				return 2
+			}
+			thresholds = loaded
+		}
+		capacity = thresholds.BreakerAmps * thresholds.AlertPercent / 100
+	}
+
+	filter := opts.filter
+	if filter.Metric == "" {
+		filter.Metric = "max"
+	}
+	if filter.Metric != "max" && filter.Metric != "p95" {
+		fmt.Printf("Invalid --metric %q for forecast (use max or p95)\n", filter.Metric)
+		return 2
+	}
+
+	entries, err := store.Query(filter)
+	if err != nil {
+		log.Printf("Querying history failed: %v", err)
+		return 1
+	}
+
+	// Group the months of each rack phase; entries arrive ordered by month
+	var forecasts []CapacityForecast
+	for start := 0; start < len(entries); {
+		first := entries[start]
+		end := start
+		var points []history.Point
+		for ; end < len(entries); end++ {
+			e := entries[end]
+			if e.PDU != first.PDU || e.Rack != first.Rack || e.Phase != first.Phase {
+				break
+			}
+			points = append(points, history.Point{Month: e.Month, Value: e.Value})
+		}
+		start = end
+
+		// Breakers are per phase; the summary is only forecast when asked for
+		if first.Phase == "summary" && filter.Phase == "" {
+			continue
+		}
+		base := CapacityForecast{PDU: first.PDU, Rack: first.Rack, Phase: first.Phase, Metric: first.Metric, Capacity: capacity}
+		forecasts = append(forecasts, forecastCapacity(points, base, opts.methods, opts.horizon)...)
+	}
+
+	if len(forecasts) == 0 {
+		fmt.Printf("⚠️  No %s history matches the filter in %s\n", filter.Metric, opts.dbFile)
+		return 1
+	}
+
+	records := [][]string{{"PDU", "Rack", "Phase", "Metric", "Method", "Months", "Last Month", "Last Value",
+		"Trend per Month", "Capacity", "Status", "Crossing Month", "Earliest (95%)", "Latest (95%)", "Note"}}
+	for _, c := range forecasts {
+		records = append(records, c.record())
+	}
+	if err := writeHistoryCSV(opts.outputFile, records); err != nil {
+		log.Printf("Writing forecast failed: %v", err)
+		return 1
+	}
+
+	// Console summary of the phases heading for their limit
+	if opts.outputFile != "" {
+		fmt.Printf("Capacity: %.3f A (%s)\n", capacity, filter.Metric)
+		for _, c := range forecasts {
+			switch c.Status {
+			case forecastOver:
+				fmt.Printf("⚠️  %s %s %s already at %.3f in %s\n", c.PDU, c.Rack, c.Phase, c.LastValue, c.LastMonth)
+			case forecastCrossing:
+				fmt.Printf("⚠️  %s %s %s reaches capacity in %s (95%%: %s – %s, %s)\n", c.PDU, c.Rack, c.Phase,
+					c.Crossing, c.Earliest, orAfter(c.Latest, c.Horizon), c.Method)
+			}
+		}
+	}
+	return 0
+}
+
+// orAfter returns month, or "after <horizon>" when the month lies beyond the horizon
+func orAfter(month, horizon string) string {
+	if month == "" {
+		return "after " + horizon
+	}
+	return month
+}
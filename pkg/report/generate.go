@@ -0,0 +1,325 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/reski-rukmantiyo/bdx-parser/pkg/inventory"
+	"github.com/xuri/excelize/v2"
+)
+
+// templateSheet is the sheet name of generated monthly templates
+const templateSheet = "Average Power PDU"
+
+// columnTitles are the header texts written by the generator; they match the
+// header patterns of the built-in layout so the filler can locate them
+var columnTitles = map[string]string{
+	"L1Min": "Current L1 Min", "L1Avg": "Current L1 AVG", "L1Max": "Current L1 Max",
+	"L2Min": "Current L2 Min", "L2Avg": "Current L2 AVG", "L2Max": "Current L2 Max",
+	"L3Min": "Current L3 Min", "L3Avg": "Current L3 AVG", "L3Max": "Current L3 Max",
+	"SummaryMin": "Current Min", "SummaryAvg": "Current AVG", "SummaryMax": "Current Max",
+}
+
+// columnGroups are the merged group headers above the column headers
+var columnGroups = []struct {
+	Title string
+	First string
+	Last  string
+}{
+	{"L1", "L1Min", "L1Max"},
+	{"L2", "L2Min", "L2Max"},
+	{"L3", "L3Min", "L3Max"},
+	{"Summary per Rack", "SummaryMin", "SummaryMax"},
+}
+
+// TemplateSpec lists the PDUs and racks of a generated template
+type TemplateSpec struct {
+	Month time.Time
+	PDUs  []string
+	Racks map[string][]string // PDU name -> rack labels
+	State inventory.Inventory // optional, written to the Status column
+}
+
+// templateStyles holds the style IDs used by the generator
+type templateStyles struct {
+	title, group, header, section, rack, cell int
+}
+
+// GenerateTemplate writes a fresh monthly report workbook following the layout
+func (mf *MonthlyFiller) GenerateTemplate(spec TemplateSpec, filename string) error {
+	f := excelize.NewFile()
+	defer f.Close()
+
+	if err := f.SetSheetName(f.GetSheetName(0), templateSheet); err != nil {
+		return fmt.Errorf("failed to name sheet: %v", err)
+	}
+
+	styles, err := newTemplateStyles(f)
+	if err != nil {
+		return err
+	}
+
+	mapping := mf.layout.ColumnMapping()
+	width := mf.layout.Width()
+	label := mf.layout.LabelColumn
+
+	// Title, group headers and column headers above the first section
+	title := fmt.Sprintf("Average Power PDU - %s", spec.Month.Format("January 2006"))
+	if err := mf.setCell(f, 0, label, title, styles.title); err != nil {
+		return err
+	}
+	for _, group := range columnGroups {
+		first, last := mapping[group.First], mapping[group.Last]
+		if err := mf.setCell(f, 1, first, group.Title, styles.group); err != nil {
+			return err
+		}
+		if last > first {
+			from, _ := excelize.CoordinatesToCellName(first+1, 2)
+			to, _ := excelize.CoordinatesToCellName(last+1, 2)
+			if err := f.MergeCell(templateSheet, from, to); err != nil {
+				return fmt.Errorf("failed to merge group header: %v", err)
+			}
+		}
+	}
+	if err := mf.setCell(f, 2, label, "Rack", styles.header); err != nil {
+		return err
+	}
+	if spec.State != nil && label+1 < width && !mf.isMetricColumn(label+1) {
+		if err := mf.setCell(f, 2, label+1, "Status", styles.header); err != nil {
+			return err
+		}
+	}
+	for key, col := range mapping {
+		if err := mf.setCell(f, 2, col, columnTitles[key], styles.header); err != nil {
+			return err
+		}
+	}
+
+	// One section per PDU: "PDU X" marker followed by its rack rows
+	row := 3
+	for _, pduName := range spec.PDUs {
+		if err := mf.setCell(f, row, label, "PDU "+pduName, styles.section); err != nil {
+			return err
+		}
+		if err := mf.styleRow(f, row, label+1, width-1, styles.section); err != nil {
+			return err
+		}
+		row++
+
+		for _, rack := range spec.Racks[pduName] {
+			if err := mf.setCell(f, row, label, rack, styles.rack); err != nil {
+				return err
+			}
+			if err := mf.styleRow(f, row, label+1, width-1, styles.cell); err != nil {
+				return err
+			}
+			if state, ok := spec.State.State(pduName, rack); ok && label+1 < width && !mf.isMetricColumn(label+1) {
+				if err := mf.setCell(f, row, label+1, state.String(), styles.cell); err != nil {
+					return err
+				}
+			}
+			row++
+		}
+	}
+
+	// Column widths and frozen header
+	first, _ := excelize.ColumnNumberToName(label + 1)
+	if err := f.SetColWidth(templateSheet, first, first, 12); err != nil {
+		return fmt.Errorf("failed to set column width: %v", err)
+	}
+	if width > label+1 {
+		from, _ := excelize.ColumnNumberToName(label + 2)
+		to, _ := excelize.ColumnNumberToName(width)
+		if err := f.SetColWidth(templateSheet, from, to, 15); err != nil {
+			return fmt.Errorf("failed to set column width: %v", err)
+		}
+	}
+	if err := f.SetPanes(templateSheet, &excelize.Panes{Freeze: true, YSplit: 3, TopLeftCell: "A4", ActivePane: "bottomLeft"}); err != nil {
+		return fmt.Errorf("failed to freeze header: %v", err)
+	}
+
+	if err := f.SaveAs(filename); err != nil {
+		return fmt.Errorf("failed to save template: %v", err)
+	}
+
+	fmt.Printf("Generated template %s: %d PDU sections, %d rows\n", filename, len(spec.PDUs), row)
+	return nil
+}
+
+// newTemplateStyles registers the generator's cell styles
+func newTemplateStyles(f *excelize.File) (templateStyles, error) {
+	border := []excelize.Border{
+		{Type: "left", Color: "A6A6A6", Style: 1},
+		{Type: "right", Color: "A6A6A6", Style: 1},
+		{Type: "top", Color: "A6A6A6", Style: 1},
+		{Type: "bottom", Color: "A6A6A6", Style: 1},
+	}
+	center := &excelize.Alignment{Horizontal: "center", Vertical: "center"}
+
+	// Same order as the fields of templateStyles
+	definitions := []*excelize.Style{
+		{Font: &excelize.Font{Bold: true, Size: 14}},
+		{Font: &excelize.Font{Bold: true, Color: "FFFFFF"}, Fill: excelize.Fill{Type: "pattern", Pattern: 1, Color: []string{"1F4E78"}}, Alignment: center, Border: border},
+		{Font: &excelize.Font{Bold: true}, Fill: excelize.Fill{Type: "pattern", Pattern: 1, Color: []string{"DDEBF7"}}, Alignment: &excelize.Alignment{Horizontal: "center", Vertical: "center", WrapText: true}, Border: border},
+		{Font: &excelize.Font{Bold: true}, Fill: excelize.Fill{Type: "pattern", Pattern: 1, Color: []string{"FCE4D6"}}, Border: border},
+		{Font: &excelize.Font{Bold: true}, Border: border},
+		{Border: border, NumFmt: 2}, // 0.00
+	}
+
+	var styles templateStyles
+	ids := []*int{&styles.title, &styles.group, &styles.header, &styles.section, &styles.rack, &styles.cell}
+	for i, def := range definitions {
+		id, err := f.NewStyle(def)
+		if err != nil {
+			return styles, fmt.Errorf("failed to create style: %v", err)
+		}
+		*ids[i] = id
+	}
+	return styles, nil
+}
+
+// setCell writes a value with a style at 0-based row and column
+func (mf *MonthlyFiller) setCell(f *excelize.File, row, col int, value interface{}, style int) error {
+	cell, err := excelize.CoordinatesToCellName(col+1, row+1)
+	if err != nil {
+		return err
+	}
+	if err := f.SetCellValue(templateSheet, cell, value); err != nil {
+		return fmt.Errorf("failed to write %s: %v", cell, err)
+	}
+	return f.SetCellStyle(templateSheet, cell, cell, style)
+}
+
+// styleRow applies a style to columns first..last of a 0-based row
+func (mf *MonthlyFiller) styleRow(f *excelize.File, row, first, last, style int) error {
+	if last < first {
+		return nil
+	}
+	from, _ := excelize.CoordinatesToCellName(first+1, row+1)
+	to, _ := excelize.CoordinatesToCellName(last+1, row+1)
+	return f.SetCellStyle(templateSheet, from, to, style)
+}
+
+// isMetricColumn reports whether a column holds one of the layout's metrics
+func (mf *MonthlyFiller) isMetricColumn(col int) bool {
+	for _, c := range mf.layout.ColumnMapping() {
+		if c == col {
+			return true
+		}
+	}
+	return false
+}
+
+// standardRacks returns Q1..Qn followed by any extra rack labels not already included
+func standardRacks(n int, extra []string) []string {
+	racks := make([]string, 0, n+len(extra))
+	seen := make(map[string]bool)
+	for q := 1; q <= n; q++ {
+		rack := fmt.Sprintf("Q%d", q)
+		racks = append(racks, rack)
+		seen[rack] = true
+	}
+	for _, rack := range extra {
+		if !seen[rack] {
+			racks = append(racks, rack)
+			seen[rack] = true
+		}
+	}
+	return racks
+}
+
+// runGenerateTemplate implements "generate-template" and returns the exit code
+func runGenerateTemplate(args []string) int {
+	filler := NewMonthlyFiller()
+	month := time.Now().AddDate(0, -1, 0) // Default: the month being reported
+	inventoryFile := ""
+	pduList := ""
+	racks := 0 // Default: racks_per_section of the layout
+	outputFile := ""
+
+	for i := 0; i < len(args); i++ {
+		if i+1 >= len(args) {
+			fmt.Printf("Missing value for %s\n", args[i])
+			return 2
+		}
+		value := args[i+1]
+		switch args[i] {
+		case "-m", "--month":
+			parsed, err := time.Parse("2006-01", value)
+			if err != nil {
+				fmt.Printf("Invalid month %q (use YYYY-MM)\n", value)
+				return 2
+			}
+			month = parsed
+		case "-i", "--inventory":
+			inventoryFile = value
+		case "--pdus":
+			pduList = value
+		case "--racks":
+			if _, err := fmt.Sscanf(value, "%d", &racks); err != nil || racks <= 0 {
+				fmt.Printf("Invalid rack count %q\n", value)
+				return 2
+			}
+		case "-o", "--output":
+			outputFile = value
+		case "-l", "--layout":
+			layout, err := LoadLayout(value)
+			if err != nil {
+				log.Printf("Loading layout failed: %v", err)
+				return 2
+			}
+			filler.layout = layout
+		default:
+			fmt.Printf("Unknown option %s\n", args[i])
+			return 2
+		}
+		i++ // Skip value
+	}
+
+	if racks == 0 {
+		racks = filler.layout.RacksPerSection
+	}
+
+	spec := TemplateSpec{Month: month, Racks: make(map[string][]string)}
+	switch {
+	case inventoryFile != "":
+		inv, err := inventory.Load(inventoryFile)
+		if err != nil {
+			log.Printf("Loading inventory failed: %v", err)
+			return 2
+		}
+		spec.State = inv
+		spec.PDUs = inv.PDUs()
+		for _, pduName := range spec.PDUs {
+			spec.Racks[pduName] = standardRacks(racks, inv.Racks(pduName))
+		}
+	case pduList != "":
+		for _, pduName := range strings.Split(pduList, ",") {
+			pduName = strings.ToUpper(strings.TrimSpace(pduName))
+			if pduName == "" {
+				continue
+			}
+			spec.PDUs = append(spec.PDUs, pduName)
+			spec.Racks[pduName] = standardRacks(racks, nil)
+		}
+	default:
+		fmt.Println("Usage: monthly-filler generate-template (-i inventory.csv | --pdus A1,A2,...) [-m YYYY-MM] [--racks n] [-o file.xlsx] [-l layout.json]")
+		return 2
+	}
+	if len(spec.PDUs) == 0 {
+		fmt.Println("No PDUs to generate")
+		return 2
+	}
+
+	if outputFile == "" {
+		outputFile = fmt.Sprintf("monthly-%s-%d.xlsx", strings.ToLower(month.Format("January")), month.Year())
+	}
+
+	if err := filler.GenerateTemplate(spec, outputFile); err != nil {
+		log.Printf("Generating template failed: %v", err)
+		return 1
+	}
+	return 0
+}
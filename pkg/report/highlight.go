@@ -0,0 +1,321 @@
+package main
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"strings"
+
+	"github.com/xuri/excelize/v2"
+)
+
+// defaultThresholdsJSON holds the built-in highlighting thresholds
+//
+//go:embed default_thresholds.json
+var defaultThresholdsJSON []byte
+
+// legendSheet is the sheet explaining the highlight colours
+const legendSheet = "Legend"
+
+// Thresholds controls the highlighting of XLSX reports
+type Thresholds struct {
+	BreakerAmps      float64         `json:"breaker_amps"`      // per-phase breaker rating
+	WarnPercent      float64         `json:"warn_percent"`      // amber at or above this share of the breaker
+	AlertPercent     float64         `json:"alert_percent"`     // red at or above this share of the breaker
+	ImbalancePercent float64         `json:"imbalance_percent"` // spread of the phase averages relative to their mean
+	Colors           ThresholdColors `json:"colors"`
+}
+
+// ThresholdColors are the fill colours (RGB hex) of each highlight level
+type ThresholdColors struct {
+	Warn      string `json:"warn"`
+	Alert     string `json:"alert"`
+	NoData    string `json:"no_data"`
+	Imbalance string `json:"imbalance"`
+}
+
+// Highlight levels of a metric cell
+const (
+	levelOK = iota
+	levelWarn
+	levelAlert
+	levelNoData
+	levelImbalance
+)
+
+// DefaultThresholds returns the built-in thresholds
+func DefaultThresholds() *Thresholds {
+	t, err := parseThresholds(defaultThresholdsJSON, nil)
+	if err != nil {
+		panic(fmt.Sprintf("invalid built-in thresholds: %v", err))
+	}
+	return t
+}
+
+// LoadThresholds reads a thresholds file; fields it omits keep their default values
+func LoadThresholds(filename string) (*Thresholds, error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read thresholds file: %v", err)
+	}
+
+	t, err := parseThresholds(data, DefaultThresholds())
+	if err != nil {
+		return nil, fmt.Errorf("invalid thresholds %s: %v", filename, err)
+	}
+
+	fmt.Printf("Loaded highlight thresholds from %s\n", filename)
+	return t, nil
+}
+
+// parseThresholds decodes thresholds on top of base (if any) and validates them
+func parseThresholds(data []byte, base *Thresholds) (*Thresholds, error) {
+	t := &Thresholds{}
+	if base != nil {
+		*t = *base
+	}
+	if err := json.Unmarshal(data, t); err != nil {
+		return nil, err
+	}
+
+	if t.BreakerAmps <= 0 {
+		return nil, fmt.Errorf("breaker_amps must be positive")
+	}
+	if t.WarnPercent <= 0 || t.AlertPercent < t.WarnPercent {
+		return nil, fmt.Errorf("need 0 < warn_percent <= alert_percent")
+	}
+	if t.ImbalancePercent <= 0 {
+		return nil, fmt.Errorf("imbalance_percent must be positive")
+	}
+	return t, nil
+}
+
+// level classifies a metric cell against the breaker thresholds; formula cells are
+// classified by the value the filler computed for them
+func (t *Thresholds) level(cell Cell) int {
+	value, ok := cell.Number()
+	switch {
+	case !ok && strings.EqualFold(strings.TrimSpace(cell.Text), noDataLabel):
+		return levelNoData
+	case !ok:
+		return levelOK
+	case value >= t.BreakerAmps*t.AlertPercent/100:
+		return levelAlert
+	case value >= t.BreakerAmps*t.WarnPercent/100:
+		return levelWarn
+	}
+	return levelOK
+}
+
+// color returns the fill colour of a level
+func (t *Thresholds) color(level int) string {
+	switch level {
+	case levelWarn:
+		return t.Colors.Warn
+	case levelAlert:
+		return t.Colors.Alert
+	case levelNoData:
+		return t.Colors.NoData
+	case levelImbalance:
+		return t.Colors.Imbalance
+	}
+	return ""
+}
+
+// imbalance returns the spread of the phase averages relative to their mean, in
+// percent, or NaN if fewer than two phases have data
+func imbalance(averages ...Cell) float64 {
+	var values []float64
+	for _, cell := range averages {
+		if v, ok := cell.Number(); ok {
+			values = append(values, v)
+		}
+	}
+	if len(values) < 2 {
+		return math.NaN()
+	}
+
+	min, max, sum := values[0], values[0], 0.0
+	for _, v := range values {
+		min = math.Min(min, v)
+		max = math.Max(max, v)
+		sum += v
+	}
+	mean := sum / float64(len(values))
+	if mean == 0 {
+		return math.NaN()
+	}
+	return (max - min) / mean * 100
+}
+
+// highlighter derives highlighted variants of existing cell styles
+type highlighter struct {
+	f          *excelize.File
+	sheet      string
+	thresholds *Thresholds
+	styles     map[[2]int]int // (base style, level) -> style
+	counts     map[int]int
+}
+
+// highlight restyles one cell for a level, keeping its font, borders and number format.
+// Cells back at levelOK lose a fill left by an earlier run.
+func (h *highlighter) highlight(row, col, level int) error {
+	name, _ := excelize.CoordinatesToCellName(col+1, row+1)
+	base, err := h.f.GetCellStyle(h.sheet, name)
+	if err != nil {
+		return err
+	}
+	h.counts[level]++
+
+	key := [2]int{base, level}
+	id, ok := h.styles[key]
+	if !ok {
+		style, err := h.f.GetStyle(base)
+		if err != nil {
+			return err
+		}
+		if level == levelOK {
+			if !h.isHighlightFill(style.Fill) {
+				h.styles[key] = base
+				return nil
+			}
+			style.Fill = excelize.Fill{}
+		} else {
+			style.Fill = excelize.Fill{Type: "pattern", Pattern: 1, Color: []string{h.thresholds.color(level)}}
+		}
+		if id, err = h.f.NewStyle(style); err != nil {
+			return err
+		}
+		h.styles[key] = id
+	}
+	if id == base {
+		return nil
+	}
+	return h.f.SetCellStyle(h.sheet, name, name, id)
+}
+
+// isHighlightFill reports whether a fill is one of the highlight colours
+func (h *highlighter) isHighlightFill(fill excelize.Fill) bool {
+	if fill.Type != "pattern" || len(fill.Color) == 0 {
+		return false
+	}
+	color := strings.TrimPrefix(strings.ToUpper(fill.Color[0]), "#")
+	for level := levelWarn; level <= levelImbalance; level++ {
+		if strings.EqualFold(color, h.thresholds.color(level)) {
+			return true
+		}
+	}
+	return false
+}
+
+// applyHighlights colours the metric cells written by the filler and marks the
+// labels of racks whose phases are unbalanced
+func (mf *MonthlyFiller) applyHighlights(f *excelize.File, sheet string) error {
+	h := &highlighter{f: f, sheet: sheet, thresholds: mf.thresholds,
+		styles: make(map[[2]int]int), counts: make(map[int]int)}
+	mapping := mf.layout.ColumnMapping()
+
+	// Summary cells are per-phase amps only for the default, mean and max-phase rules
+	keys := layoutColumns[:9]
+	switch mf.pduData.SummaryRule {
+	case "", "mean", "max-phase":
+		keys = layoutColumns
+	}
+
+	for _, section := range mf.pduSections {
+		for _, row := range section.RackRows {
+			written := false
+			for _, key := range keys {
+				cell := cellAt(mf.monthlyData[row], mapping[key])
+				if !cell.Written {
+					continue
+				}
+				written = true
+				if err := h.highlight(row, mapping[key], mf.thresholds.level(cell)); err != nil {
+					return fmt.Errorf("failed to highlight row %d: %v", row+1, err)
+				}
+			}
+			if !written {
+				continue
+			}
+
+			level := levelOK
+			spread := imbalance(cellAt(mf.monthlyData[row], mapping["L1Avg"]),
+				cellAt(mf.monthlyData[row], mapping["L2Avg"]), cellAt(mf.monthlyData[row], mapping["L3Avg"]))
+			if spread > mf.thresholds.ImbalancePercent {
+				level = levelImbalance
+			}
+			if err := h.highlight(row, mf.layout.LabelColumn, level); err != nil {
+				return fmt.Errorf("failed to highlight row %d: %v", row+1, err)
+			}
+		}
+	}
+
+	fmt.Printf("Highlighted: %d red, %d amber, %d no data, %d unbalanced racks\n",
+		h.counts[levelAlert], h.counts[levelWarn], h.counts[levelNoData], h.counts[levelImbalance])
+	return mf.writeLegend(f)
+}
+
+// writeLegend (re)creates the sheet explaining the highlight colours
+func (mf *MonthlyFiller) writeLegend(f *excelize.File) error {
+	if index, _ := f.GetSheetIndex(legendSheet); index >= 0 {
+		if err := f.DeleteSheet(legendSheet); err != nil {
+			return fmt.Errorf("failed to replace legend: %v", err)
+		}
+	}
+	if _, err := f.NewSheet(legendSheet); err != nil {
+		return fmt.Errorf("failed to create legend: %v", err)
+	}
+
+	t := mf.thresholds
+	entries := []struct {
+		level int
+		text  string
+	}{
+		{levelAlert, fmt.Sprintf("At or above %g%% of the %g A breaker (%.1f A)", t.AlertPercent, t.BreakerAmps, t.BreakerAmps*t.AlertPercent/100)},
+		{levelWarn, fmt.Sprintf("At or above %g%% of the %g A breaker (%.1f A)", t.WarnPercent, t.BreakerAmps, t.BreakerAmps*t.WarnPercent/100)},
+		{levelNoData, "No data: idle, unpopulated or missing rack"},
+		{levelImbalance, fmt.Sprintf("Rack label: phase averages differ by more than %g%% of their mean", t.ImbalancePercent)},
+	}
+
+	bold, err := f.NewStyle(&excelize.Style{Font: &excelize.Font{Bold: true}})
+	if err != nil {
+		return err
+	}
+	if err := f.SetCellStr(legendSheet, "A1", "Colour"); err != nil {
+		return fmt.Errorf("failed to write legend: %v", err)
+	}
+	if err := f.SetCellStr(legendSheet, "B1", "Meaning"); err != nil {
+		return fmt.Errorf("failed to write legend: %v", err)
+	}
+	if err := f.SetCellStyle(legendSheet, "A1", "B1", bold); err != nil {
+		return fmt.Errorf("failed to write legend: %v", err)
+	}
+	for i, entry := range entries {
+		row := i + 2
+		style, err := f.NewStyle(&excelize.Style{Fill: excelize.Fill{Type: "pattern", Pattern: 1, Color: []string{t.color(entry.level)}}})
+		if err != nil {
+			return err
+		}
+		colorCell, err := excelize.CoordinatesToCellName(1, row)
+		if err != nil {
+			return err
+		}
+		meaningCell, err := excelize.CoordinatesToCellName(2, row)
+		if err != nil {
+			return err
+		}
+		if err := f.SetCellStyle(legendSheet, colorCell, colorCell, style); err != nil {
+			return fmt.Errorf("failed to write legend: %v", err)
+		}
+		if err := f.SetCellStr(legendSheet, meaningCell, entry.text); err != nil {
+			return fmt.Errorf("failed to write legend: %v", err)
+		}
+	}
+	if err := f.SetCellStr(legendSheet, "A7", "Thresholds apply to the L1-L3 min/avg/max cells and, for per-phase summaries, to Current Min/AVG/Max."); err != nil {
+		return fmt.Errorf("failed to write legend: %v", err)
+	}
+	return f.SetColWidth(legendSheet, "B", "B", 70)
+}
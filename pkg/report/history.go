@@ -0,0 +1,384 @@
+package main
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/reski-rukmantiyo/bdx-parser/pkg/history"
+	"github.com/reski-rukmantiyo/bdx-parser/pkg/pdustats"
+)
+
+// historyUsage lists the history subcommands
+const historyUsage = `Usage: monthly-filler history <command> [options]
+  import <files...> [--db file] [-m YYYY-MM]  Store parser outputs (total_xx.csv needs -m, JSON carries its period)
+  query [--db file] [--pdu B3] [--rack Q7] [--phase l1|l2|l3|summary] [--metric min|avg|max|p95]
+        [--from YYYY-MM] [--to YYYY-MM] [-o history.csv]
+  ytd [--db file] [--year 2025] [--pdu B3] [-o ytd.csv]  Year-to-date min, mean of averages and peak
+  forecast [--db file] [--metric max|p95] [--method linear|seasonal-naive|all] [--capacity A]
+        [--thresholds file] [--horizon 24] [--pdu B3] [--rack Q7] [--phase l1] [-o forecast.csv]`
+
+// resultFromPDUData converts statistics loaded from a legacy total_xx.csv into a result.
+// The CSV carries neither sample counts nor rack states, so both are left empty.
+// Parsers before N/A support wrote 0.000 for racks without data; zeros are stored as
+// no data, as zero readings are everywhere else, so they don't drag minimums down.
+func resultFromPDUData(d *PDUData, sourceFile string) *pdustats.Result {
+	result := pdustats.NewResult(d.PDUName)
+	result.SourceFile = sourceFile
+	if d.SummaryRule != "" {
+		result.Summary = &pdustats.SummaryRule{Rule: d.SummaryRule, Unit: d.SummaryUnit}
+	}
+
+	value := func(series []float64, q int) *float64 {
+		if q >= len(series) || math.IsNaN(series[q]) || series[q] == 0 {
+			return nil
+		}
+		v := series[q]
+		return &v
+	}
+
+	for q := 0; q < 18; q++ {
+		rack := pdustats.Rack{Rack: fmt.Sprintf("Q%d", q+1), Phases: make(map[string]pdustats.Phase)}
+		hasData := false
+		for _, phase := range pdustats.Phases {
+			p := pdustats.Phase{
+				Min: value(d.Series(phase+" min"), q),
+				Avg: value(d.Series(phase+" avg"), q),
+				Max: value(d.Series(phase+" max"), q),
+			}
+			hasData = hasData || p.Min != nil || p.Avg != nil || p.Max != nil
+			rack.Phases[phase] = p
+		}
+		if !hasData {
+			continue
+		}
+		if d.SummaryRule != "" {
+			rack.Summary = &pdustats.Phase{
+				Min: value(d.SummaryMin, q),
+				Avg: value(d.SummaryAvg, q),
+				Max: value(d.SummaryMax, q),
+			}
+		}
+		result.Racks = append(result.Racks, rack)
+	}
+	return result
+}
+
+// importHistory stores one parser output file and returns the number of values recorded
+func importHistory(store *history.Store, filename, month string) (int, error) {
+	var result *pdustats.Result
+	if strings.EqualFold(filepath.Ext(filename), ".json") {
+		var err error
+		if result, err = pdustats.Read(filename); err != nil {
+			return 0, err
+		}
+	} else {
+		if month == "" {
+			return 0, fmt.Errorf("%s has no period; give its month with -m YYYY-MM", filename)
+		}
+		filler := NewMonthlyFiller()
+		if err := filler.LoadPDUData(filename); err != nil {
+			return 0, err
+		}
+		result = resultFromPDUData(&filler.pduData, filename)
+	}
+
+	hash, err := history.FileHash(filename)
+	if err != nil {
+		return 0, fmt.Errorf("failed to hash %s: %v", filename, err)
+	}
+	entries, err := history.FromResult(result, hash, month)
+	if err != nil {
+		return 0, err
+	}
+	if err := store.Record(entries); err != nil {
+		return 0, err
+	}
+	return len(entries), nil
+}
+
+// rackFilter normalises a rack filter so "7" and "q7" both mean Q7
+func rackFilter(rack string) string {
+	if _, err := strconv.Atoi(rack); err == nil {
+		return "Q" + rack
+	}
+	return strings.ToUpper(rack)
+}
+
+// YTDRow is the year-to-date aggregate of one rack phase
+type YTDRow struct {
+	PDU, Rack, Phase string
+	Months           int
+	Min, Avg, Max    float64
+	PeakMonth        string
+}
+
+// yearToDate aggregates monthly entries: lowest minimum, mean of the monthly
+// averages and highest maximum with the month it occurred in
+func yearToDate(entries []history.Entry) []YTDRow {
+	var rows []YTDRow
+	index := make(map[string]int)
+	months := make(map[string]map[string]bool)
+	avgSums := make(map[string]float64)
+	avgCounts := make(map[string]int)
+
+	for _, e := range entries {
+		key := e.PDU + "|" + e.Rack + "|" + e.Phase
+		i, ok := index[key]
+		if !ok {
+			i = len(rows)
+			index[key] = i
+			months[key] = make(map[string]bool)
+			rows = append(rows, YTDRow{PDU: e.PDU, Rack: e.Rack, Phase: e.Phase,
+				Min: math.NaN(), Avg: math.NaN(), Max: math.NaN()})
+		}
+		row := &rows[i]
+		months[key][e.Month] = true
+		row.Months = len(months[key])
+
+		switch e.Metric {
+		case "min":
+			if math.IsNaN(row.Min) || e.Value < row.Min {
+				row.Min = e.Value
+			}
+		case "avg":
+			avgSums[key] += e.Value
+			avgCounts[key]++
+			row.Avg = avgSums[key] / float64(avgCounts[key])
+		case "max":
+			if math.IsNaN(row.Max) || e.Value > row.Max {
+				row.Max = e.Value
+				row.PeakMonth = e.Month
+			}
+		}
+	}
+	return rows
+}
+
+// formatHistoryValue formats a stored value like the parser does
+func formatHistoryValue(v float64) string {
+	if math.IsNaN(v) {
+		return noDataLabel
+	}
+	return fmt.Sprintf("%.3f", v)
+}
+
+// writeHistoryCSV writes records to the output file, or to stdout when none is given
+func writeHistoryCSV(outputFile string, records [][]string) error {
+	write := func(w io.Writer) error {
+		writer := csv.NewWriter(w)
+		if err := writer.WriteAll(records); err != nil {
+			return err
+		}
+		return writer.Error()
+	}
+	if outputFile == "" {
+		return write(os.Stdout)
+	}
+	if err := writeFileAtomic(outputFile, write); err != nil {
+		return err
+	}
+	fmt.Printf("✅ Wrote %d rows to %s\n", len(records)-1, outputFile)
+	return nil
+}
+
+// historyOptions are the parsed flags of a history subcommand
+type historyOptions struct {
+	dbFile     string
+	outputFile string
+	month      string
+	year       string
+	filter     history.Filter
+	files      []string
+
+	methods    []string
+	capacity   float64
+	thresholds string
+	horizon    int
+}
+
+// runHistory handles "monthly-filler history <command> ..."
+func runHistory(args []string) int {
+	if len(args) == 0 {
+		fmt.Println(historyUsage)
+		return 2
+	}
+	command := args[0]
+
+	opts := historyOptions{
+		dbFile:  history.DefaultPath,
+		year:    strconv.Itoa(time.Now().Year()),
+		methods: []string{history.MethodLinear, history.MethodSeasonal},
+		horizon: defaultForecastHorizon,
+	}
+
+	for i := 1; i < len(args); i++ {
+		switch args[i] {
+		case "--db", "-o", "--output", "-m", "--month", "--year", "--pdu", "--rack", "--phase", "--metric", "--from", "--to",
+			"--method", "--capacity", "--thresholds", "--horizon":
+			if i+1 >= len(args) {
+				fmt.Printf("Missing value for %s\n", args[i])
+				return 2
+			}
+			value := args[i+1]
+			switch args[i] {
+			case "--db":
+				opts.dbFile = value
+			case "-o", "--output":
+				opts.outputFile = value
+			case "-m", "--month":
+				opts.month = value
+			case "--year":
+				opts.year = value
+			case "--pdu":
+				opts.filter.PDU = value
+			case "--rack":
+				opts.filter.Rack = rackFilter(value)
+			case "--phase":
+				opts.filter.Phase = value
+			case "--metric":
+				opts.filter.Metric = value
+			case "--from":
+				opts.filter.FromMonth = value
+			case "--to":
+				opts.filter.ToMonth = value
+			case "--method":
+				switch strings.ToLower(value) {
+				case "all":
+				case history.MethodLinear, history.MethodSeasonal:
+					opts.methods = []string{strings.ToLower(value)}
+				default:
+					fmt.Printf("Invalid --method %q (use linear, seasonal-naive or all)\n", value)
+					return 2
+				}
+			case "--capacity":
+				parsed, err := strconv.ParseFloat(value, 64)
+				if err != nil || parsed <= 0 {
+					fmt.Printf("Invalid capacity %q\n", value)
+					return 2
+				}
+				opts.capacity = parsed
+			case "--thresholds":
+				opts.thresholds = value
+			case "--horizon":
+				parsed, err := strconv.Atoi(value)
+				if err != nil || parsed <= 0 {
+					fmt.Printf("Invalid horizon %q\n", value)
+					return 2
+				}
+				opts.horizon = parsed
+			}
+			i++ // Skip value
+		default:
+			opts.files = append(opts.files, args[i])
+		}
+	}
+
+	for _, m := range []string{opts.month, opts.filter.FromMonth, opts.filter.ToMonth} {
+		if m == "" {
+			continue
+		}
+		if _, err := time.Parse(history.MonthLayout, m); err != nil {
+			fmt.Printf("Invalid month %q (use YYYY-MM)\n", m)
+			return 2
+		}
+	}
+
+	var run func(*history.Store, historyOptions) int
+	switch command {
+	case "import":
+		if len(opts.files) == 0 {
+			fmt.Println(historyUsage)
+			return 2
+		}
+		run = historyImport
+	case "query":
+		run = historyQuery
+	case "ytd":
+		if _, err := strconv.Atoi(opts.year); err != nil {
+			fmt.Printf("Invalid year %q\n", opts.year)
+			return 2
+		}
+		run = historyYTD
+	case "forecast":
+		run = historyForecast
+	default:
+		fmt.Println(historyUsage)
+		return 2
+	}
+
+	store, err := history.Open(opts.dbFile)
+	if err != nil {
+		log.Printf("Opening history failed: %v", err)
+		return 1
+	}
+	defer store.Close()
+
+	return run(store, opts)
+}
+
+// historyImport stores parser outputs
+func historyImport(store *history.Store, opts historyOptions) int {
+	failed := 0
+	for _, filename := range opts.files {
+		n, err := importHistory(store, filename, opts.month)
+		if err != nil {
+			fmt.Printf("⚠️  Skipping %s: %v\n", filename, err)
+			failed++
+			continue
+		}
+		fmt.Printf("✅ Imported %d values from %s\n", n, filename)
+	}
+	if failed > 0 {
+		return 1
+	}
+	return 0
+}
+
+// historyQuery lists the stored values matching the filter
+func historyQuery(store *history.Store, opts historyOptions) int {
+	entries, err := store.Query(opts.filter)
+	if err != nil {
+		log.Printf("Querying history failed: %v", err)
+		return 1
+	}
+	records := [][]string{{"PDU", "Rack", "Phase", "Metric", "Month", "Value", "Samples", "Source File"}}
+	for _, e := range entries {
+		records = append(records, []string{e.PDU, e.Rack, e.Phase, e.Metric, e.Month,
+			formatHistoryValue(e.Value), strconv.Itoa(e.Samples), e.SourceFile})
+	}
+	if err := writeHistoryCSV(opts.outputFile, records); err != nil {
+		log.Printf("Writing history failed: %v", err)
+		return 1
+	}
+	return 0
+}
+
+// historyYTD writes the year-to-date aggregates
+func historyYTD(store *history.Store, opts historyOptions) int {
+	filter := opts.filter
+	filter.FromMonth = opts.year + "-01"
+	filter.ToMonth = opts.year + "-12"
+	entries, err := store.Query(filter)
+	if err != nil {
+		log.Printf("Querying history failed: %v", err)
+		return 1
+	}
+	records := [][]string{{"PDU", "Rack", "Phase", "Months", "YTD Min", "YTD Avg", "YTD Max", "Peak Month"}}
+	for _, row := range yearToDate(entries) {
+		records = append(records, []string{row.PDU, row.Rack, row.Phase, strconv.Itoa(row.Months),
+			formatHistoryValue(row.Min), formatHistoryValue(row.Avg), formatHistoryValue(row.Max), row.PeakMonth})
+	}
+	if err := writeHistoryCSV(opts.outputFile, records); err != nil {
+		log.Printf("Writing year-to-date report failed: %v", err)
+		return 1
+	}
+	return 0
+}
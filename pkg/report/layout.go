@@ -0,0 +1,224 @@
+package main
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// defaultLayoutJSON describes the stock monthly template (monthlyjune2025.xlsx)
+//
+//go:embed default_layout.json
+var defaultLayoutJSON []byte
+
+// layoutColumns lists the column keys every layout must define, in template order
+var layoutColumns = []string{
+	"L1Min", "L1Avg", "L1Max",
+	"L2Min", "L2Avg", "L2Max",
+	"L3Min", "L3Avg", "L3Max",
+	"SummaryMin", "SummaryAvg", "SummaryMax",
+}
+
+// ColumnSpec locates one metric column in the template
+type ColumnSpec struct {
+	Index  int    `json:"index"`  // 0-based column used when no header matches
+	Header string `json:"header"` // regexp matched against header cells above the first section
+}
+
+// TemplateLayout describes where sections, rack rows and metric columns live in a template
+type TemplateLayout struct {
+	SectionPattern  string                `json:"section_pattern"`   // regexp with one group capturing the PDU name
+	LabelColumn     int                   `json:"label_column"`      // column holding section markers and rack labels
+	RackPattern     string                `json:"rack_pattern"`      // regexp with one group capturing the rack number
+	RacksPerSection int                   `json:"racks_per_section"` // racks expected in each section (Q1..Qn)
+	Columns         map[string]ColumnSpec `json:"columns"`           // key: "L1Min" ... "SummaryMax"
+
+	sectionRe *regexp.Regexp
+	rackRe    *regexp.Regexp
+	headerRe  map[string]*regexp.Regexp
+	resolved  map[string]int  // column indices after header lookup
+	located   map[string]bool // columns found by header text
+}
+
+// DefaultLayout returns the layout of the stock monthly template
+func DefaultLayout() *TemplateLayout {
+	layout, err := parseLayout(defaultLayoutJSON, nil)
+	if err != nil {
+		panic(fmt.Sprintf("invalid built-in layout: %v", err))
+	}
+	return layout
+}
+
+// LoadLayout reads a layout descriptor; fields it omits keep their default values
+func LoadLayout(filename string) (*TemplateLayout, error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read layout file: %v", err)
+	}
+
+	layout, err := parseLayout(data, DefaultLayout())
+	if err != nil {
+		return nil, fmt.Errorf("invalid layout %s: %v", filename, err)
+	}
+
+	fmt.Printf("Loaded template layout from %s\n", filename)
+	return layout, nil
+}
+
+// parseLayout decodes a layout on top of base (if any) and compiles its patterns
+func parseLayout(data []byte, base *TemplateLayout) (*TemplateLayout, error) {
+	layout := &TemplateLayout{}
+	if base != nil {
+		*layout = *base
+		layout.Columns = make(map[string]ColumnSpec)
+		for key, spec := range base.Columns {
+			layout.Columns[key] = spec
+		}
+	}
+
+	// Unmarshal merges into the copied Columns map, so partial column lists are allowed
+	if err := json.Unmarshal(data, layout); err != nil {
+		return nil, err
+	}
+
+	if err := layout.compile(); err != nil {
+		return nil, err
+	}
+	return layout, nil
+}
+
+// compile validates the layout and prepares its regular expressions
+func (l *TemplateLayout) compile() error {
+	var err error
+	if l.sectionRe, err = regexp.Compile(l.SectionPattern); err != nil {
+		return fmt.Errorf("section_pattern: %v", err)
+	}
+	if l.sectionRe.NumSubexp() < 1 {
+		return fmt.Errorf("section_pattern must capture the PDU name in a group")
+	}
+	if l.rackRe, err = regexp.Compile(l.RackPattern); err != nil {
+		return fmt.Errorf("rack_pattern: %v", err)
+	}
+	if l.rackRe.NumSubexp() < 1 {
+		return fmt.Errorf("rack_pattern must capture the rack number in a group")
+	}
+	if l.RacksPerSection <= 0 {
+		return fmt.Errorf("racks_per_section must be positive")
+	}
+	if l.LabelColumn < 0 {
+		return fmt.Errorf("label_column must not be negative")
+	}
+
+	l.headerRe = make(map[string]*regexp.Regexp)
+	l.resolved = make(map[string]int)
+	for _, key := range layoutColumns {
+		spec, ok := l.Columns[key]
+		if !ok {
+			return fmt.Errorf("missing column %s", key)
+		}
+		if spec.Index < 0 {
+			return fmt.Errorf("column %s: index must not be negative", key)
+		}
+		if spec.Header != "" {
+			if l.headerRe[key], err = regexp.Compile(spec.Header); err != nil {
+				return fmt.Errorf("column %s header: %v", key, err)
+			}
+		}
+		l.resolved[key] = spec.Index
+	}
+	return nil
+}
+
+// SectionName returns the PDU name if the cell is a section marker
+func (l *TemplateLayout) SectionName(cell string) (string, bool) {
+	matches := l.sectionRe.FindStringSubmatch(strings.TrimSpace(cell))
+	if matches == nil {
+		return "", false
+	}
+	return strings.TrimSpace(matches[1]), true
+}
+
+// RackNumber returns the rack number if the cell is a rack label like "Q7"
+func (l *TemplateLayout) RackNumber(cell string) (int, bool) {
+	matches := l.rackRe.FindStringSubmatch(strings.TrimSpace(cell))
+	if matches == nil {
+		return 0, false
+	}
+	rack, err := strconv.Atoi(matches[1])
+	if err != nil {
+		return 0, false
+	}
+	return rack, true
+}
+
+// LocateColumns looks for header cells in rows[0:beforeRow] and uses their
+// positions instead of the fallback indices
+func (l *TemplateLayout) LocateColumns(rows [][]Cell, beforeRow int) {
+	l.located = make(map[string]bool)
+	for key, re := range l.headerRe {
+		l.resolved[key] = l.Columns[key].Index
+		for i := 0; i < beforeRow && i < len(rows); i++ {
+			col := l.findHeader(rows[i], re)
+			if col >= 0 {
+				l.resolved[key] = col
+				l.located[key] = true
+				break
+			}
+		}
+	}
+
+	if len(l.located) > 0 {
+		fmt.Printf("Located %d of %d template columns by header text\n", len(l.located), len(layoutColumns))
+	}
+}
+
+// findHeader returns the first column whose text matches re, or -1
+func (l *TemplateLayout) findHeader(row []Cell, re *regexp.Regexp) int {
+	for j, cell := range row {
+		if cell.IsEmpty() {
+			continue
+		}
+		if re.MatchString(strings.TrimSpace(cell.Text)) {
+			return j
+		}
+	}
+	return -1
+}
+
+// ColumnMapping returns the resolved column index for every metric column
+func (l *TemplateLayout) ColumnMapping() map[string]int {
+	mapping := make(map[string]int, len(l.resolved))
+	for key, col := range l.resolved {
+		mapping[key] = col
+	}
+	return mapping
+}
+
+// Width is the number of columns a row needs to hold every metric column
+func (l *TemplateLayout) Width() int {
+	width := l.LabelColumn + 1
+	for _, col := range l.resolved {
+		if col+1 > width {
+			width = col + 1
+		}
+	}
+	return width
+}
+
+// Describe prints the resolved columns in template order
+func (l *TemplateLayout) Describe() string {
+	keys := make([]string, len(layoutColumns))
+	copy(keys, layoutColumns)
+	sort.SliceStable(keys, func(i, j int) bool { return l.resolved[keys[i]] < l.resolved[keys[j]] })
+
+	parts := make([]string, len(keys))
+	for i, key := range keys {
+		parts[i] = fmt.Sprintf("%s=%d", key, l.resolved[key])
+	}
+	return strings.Join(parts, " ")
+}
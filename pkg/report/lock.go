@@ -0,0 +1,173 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// Defaults for the report lock
+const (
+	defaultLockTimeout = 30 * time.Second
+	lockStaleAfter     = 10 * time.Minute
+	lockPollInterval   = 250 * time.Millisecond
+	lockRefreshEvery   = lockStaleAfter / 4
+)
+
+// reportLock is an advisory lockfile next to the report being filled
+type reportLock struct {
+	path    string
+	stop    chan struct{}
+	stopped chan struct{}
+}
+
+// acquireLock creates <filename>.lock, waiting up to timeout for another run to finish.
+// Locks left behind by a dead process on this host, or older than lockStaleAfter, are removed.
+// The lock is touched periodically while held so long runs never look stale.
+func acquireLock(filename string, timeout time.Duration) (*reportLock, error) {
+	path := filename + ".lock"
+	hostname, _ := os.Hostname()
+	deadline := time.Now().Add(timeout)
+	waiting := false
+
+	for {
+		f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+		if err == nil {
+			_, werr := fmt.Fprintf(f, "%d\n%s\n%s\n", os.Getpid(), hostname, time.Now().Format(time.RFC3339))
+			cerr := f.Close()
+			if werr != nil || cerr != nil {
+				os.Remove(path)
+				return nil, fmt.Errorf("failed to write lock %s", path)
+			}
+			l := &reportLock{path: path, stop: make(chan struct{}), stopped: make(chan struct{})}
+			go l.refresh()
+			return l, nil
+		}
+		if !os.IsExist(err) {
+			return nil, fmt.Errorf("failed to create lock %s: %v", path, err)
+		}
+
+		owner, stale, info := inspectLock(path, hostname)
+		if stale {
+			fmt.Printf("⚠️  Removing stale lock %s (%s)\n", path, owner)
+			if err := removeStaleLock(path, info); err != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		if time.Now().After(deadline) {
+			return nil, fmt.Errorf("%s is locked by %s; try again later or remove %s if that run is gone",
+				filename, owner, path)
+		}
+		if !waiting {
+			fmt.Printf("Waiting for %s (locked by %s)...\n", filename, owner)
+			waiting = true
+		}
+		time.Sleep(lockPollInterval)
+	}
+}
+
+// inspectLock describes the lock owner and reports whether the lock is stale,
+// along with the file info of the lock that was inspected
+func inspectLock(path, hostname string) (string, bool, os.FileInfo) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return "unknown", false, nil // Removed meanwhile; the next attempt will tell
+	}
+	if time.Since(info.ModTime()) > lockStaleAfter {
+		return fmt.Sprintf("lock from %s", info.ModTime().Format(time.RFC3339)), true, info
+	}
+
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return "unknown", false, info
+	}
+	fields := strings.Split(strings.TrimSpace(string(content)), "\n")
+	if len(fields) < 2 {
+		return "unknown", false, info // Possibly still being written
+	}
+	pid, err := strconv.Atoi(fields[0])
+	if err != nil {
+		return "unknown", false, info
+	}
+	owner := fmt.Sprintf("pid %d on %s", pid, fields[1])
+
+	// Only processes on this host can be checked
+	if fields[1] == hostname && !processAlive(pid) {
+		return owner, true, info
+	}
+	return owner, false, info
+}
+
+// removeStaleLock moves the lock aside under a unique name and deletes it only if it
+// is still the lock that was inspected. A lock that was replaced or refreshed in the
+// meantime belongs to a live run and is put back.
+func removeStaleLock(path string, inspected os.FileInfo) error {
+	aside := fmt.Sprintf("%s.stale.%d.%d", path, os.Getpid(), time.Now().UnixNano())
+	if err := os.Rename(path, aside); err != nil {
+		if os.IsNotExist(err) {
+			return nil // Another waiter got there first
+		}
+		return fmt.Errorf("failed to remove stale lock %s: %v", path, err)
+	}
+
+	info, err := os.Stat(aside)
+	if err == nil && os.SameFile(info, inspected) && info.ModTime().Equal(inspected.ModTime()) {
+		if err := os.Remove(aside); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to remove stale lock %s: %v", aside, err)
+		}
+		return nil
+	}
+
+	// Link fails rather than overwriting if yet another run created a lock meanwhile
+	if err := os.Link(aside, path); err != nil {
+		return fmt.Errorf("failed to restore lock %s from %s: %v", path, aside, err)
+	}
+	os.Remove(aside)
+	return nil
+}
+
+// processAlive reports whether a process with the given pid exists
+func processAlive(pid int) bool {
+	p, err := os.FindProcess(pid)
+	if err != nil {
+		return false
+	}
+	err = p.Signal(syscall.Signal(0))
+	if err == nil || errors.Is(err, syscall.EPERM) {
+		return true
+	}
+	return !errors.Is(err, os.ErrProcessDone) && !errors.Is(err, syscall.ESRCH)
+}
+
+// refresh touches the lockfile until Release is called
+func (l *reportLock) refresh() {
+	defer close(l.stopped)
+	ticker := time.NewTicker(lockRefreshEvery)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-l.stop:
+			return
+		case <-ticker.C:
+			now := time.Now()
+			if err := os.Chtimes(l.path, now, now); err != nil {
+				fmt.Printf("⚠️  Failed to refresh lock %s: %v\n", l.path, err)
+			}
+		}
+	}
+}
+
+// Release stops refreshing and removes the lockfile
+func (l *reportLock) Release() {
+	close(l.stop)
+	<-l.stopped
+	if err := os.Remove(l.path); err != nil && !os.IsNotExist(err) {
+		fmt.Printf("⚠️  Failed to remove lock %s: %v\n", l.path, err)
+	}
+}
@@ -0,0 +1,176 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/reski-rukmantiyo/bdx-parser/pkg/pdustats"
+)
+
+// pduNameLabel is the first cell of the PDU name row in the parser's CSV output
+const pduNameLabel = "pdu"
+
+// summaryRuleLabel is the first cell of the summary rule row in the parser's CSV output
+const summaryRuleLabel = "summary"
+
+// rackLabelPattern matches rack labels like "Q7"
+var rackLabelPattern = regexp.MustCompile(`^[Qq](\d+)$`)
+
+// resolvePDUName decides which PDU a data file belongs to.
+// The name embedded in the file wins; a filename following the parser's
+// "total_<pdu>" convention must agree with it. Files without an embedded
+// name (legacy CSV) fall back to the filename.
+func (mf *MonthlyFiller) resolvePDUName(filename, contentName string) (string, error) {
+	if contentName == "" {
+		pduName, err := mf.ExtractPDUNameFromFilename(filename)
+		if err != nil {
+			return "", fmt.Errorf("%v (file has no embedded PDU name; re-run pdu-parser to add it)", err)
+		}
+		fmt.Printf("⚠️  %s has no embedded PDU name, using %s from the filename\n", filename, pduName)
+		return pduName, nil
+	}
+
+	basename := strings.ToLower(filepath.Base(filename))
+	if strings.HasPrefix(basename, "total_") {
+		if fileName, err := mf.ExtractPDUNameFromFilename(filename); err == nil && !strings.EqualFold(fileName, contentName) {
+			return "", fmt.Errorf("PDU name mismatch: %s contains data for PDU %s but its filename says %s",
+				filename, contentName, fileName)
+		}
+	}
+
+	return contentName, nil
+}
+
+// reset allocates the Q1-Q18 slices, filling them with the given value
+func (d *PDUData) reset(fill float64) {
+	for _, slice := range []*[]float64{
+		&d.L1Min, &d.L1Avg, &d.L1Max,
+		&d.L2Min, &d.L2Avg, &d.L2Max,
+		&d.L3Min, &d.L3Avg, &d.L3Max,
+		&d.SummaryMin, &d.SummaryAvg, &d.SummaryMax,
+	} {
+		*slice = make([]float64, 18)
+		for i := range *slice {
+			(*slice)[i] = fill
+		}
+	}
+	d.SummaryRule = ""
+	d.SummaryUnit = ""
+	d.DailyPeaks = nil
+}
+
+// Series returns the Q1-Q18 slice for a measurement type like "l1 min", or nil if unknown
+func (d *PDUData) Series(measurementType string) []float64 {
+	switch measurementType {
+	case "l1 min":
+		return d.L1Min
+	case "l1 avg":
+		return d.L1Avg
+	case "l1 max":
+		return d.L1Max
+	case "l2 min":
+		return d.L2Min
+	case "l2 avg":
+		return d.L2Avg
+	case "l2 max":
+		return d.L2Max
+	case "l3 min":
+		return d.L3Min
+	case "l3 avg":
+		return d.L3Avg
+	case "l3 max":
+		return d.L3Max
+	case "summary min":
+		return d.SummaryMin
+	case "summary avg":
+		return d.SummaryAvg
+	case "summary max":
+		return d.SummaryMax
+	}
+	return nil
+}
+
+// HasRack reports whether any statistic is available for the rack at index q (0 = Q1)
+func (d *PDUData) HasRack(q int) bool {
+	for _, series := range [][]float64{
+		d.L1Min, d.L1Avg, d.L1Max,
+		d.L2Min, d.L2Avg, d.L2Max,
+		d.L3Min, d.L3Avg, d.L3Max,
+	} {
+		if q < len(series) && !math.IsNaN(series[q]) {
+			return true
+		}
+	}
+	return false
+}
+
+// rackLess orders rack labels by number ("Q2" before "Q10")
+func rackLess(a, b string) bool {
+	ma, mb := rackLabelPattern.FindStringSubmatch(a), rackLabelPattern.FindStringSubmatch(b)
+	if ma == nil || mb == nil {
+		return a < b
+	}
+	na, _ := strconv.Atoi(ma[1])
+	nb, _ := strconv.Atoi(mb[1])
+	return na < nb
+}
+
+// loadPDUStats loads a versioned pdustats JSON file written by the parser
+func (mf *MonthlyFiller) loadPDUStats(filename string) error {
+	result, err := pdustats.Read(filename)
+	if err != nil {
+		return err
+	}
+
+	pduName, err := mf.resolvePDUName(filename, result.PDU)
+	if err != nil {
+		return err
+	}
+	mf.pduData.PDUName = pduName
+	mf.pduData.reset(math.NaN()) // Racks missing from the file have no data
+
+	for _, rack := range result.Racks {
+		matches := rackLabelPattern.FindStringSubmatch(rack.Rack)
+		if matches == nil {
+			fmt.Printf("⚠️  Skipping rack %q in %s: not a Q1-Q18 label\n", rack.Rack, filename)
+			continue
+		}
+		index, _ := strconv.Atoi(matches[1])
+		if index < 1 || index > 18 {
+			fmt.Printf("⚠️  Skipping rack %s in %s: outside Q1-Q18\n", rack.Rack, filename)
+			continue
+		}
+
+		for _, phaseName := range pdustats.Phases {
+			phase := rack.Phases[phaseName]
+			for _, metric := range pdustats.Metrics {
+				if value := phase.Value(metric); value != nil {
+					mf.pduData.Series(phaseName + " " + metric)[index-1] = *value
+				}
+			}
+		}
+
+		if rack.Summary != nil {
+			for _, metric := range pdustats.Metrics {
+				if value := rack.Summary.Value(metric); value != nil {
+					mf.pduData.Series("summary " + metric)[index-1] = *value
+				}
+			}
+		}
+	}
+
+	mf.pduData.DailyPeaks = result.DailyPeaks
+
+	if result.Summary != nil {
+		mf.pduData.SummaryRule = result.Summary.Rule
+		mf.pduData.SummaryUnit = result.Summary.Unit
+	}
+
+	fmt.Printf("Loaded PDU %s data from %s (schema v%d, period %s to %s)\n",
+		mf.pduData.PDUName, filename, result.SchemaVersion, result.Period.Start, result.Period.End)
+	return nil
+}
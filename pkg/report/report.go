@@ -23,17 +23,25 @@ package main
 import (
 	"encoding/csv"
 	"fmt"
+	"io"
 	"log"
+	"math"
 	"os"
 	"path/filepath"
 	"regexp"
+	"sort"
 	"strconv"
 	"strings"
+	"time"
 
-	"github.com/xuri/excelize/v2"
+	"github.com/reski-rukmantiyo/bdx-parser/pkg/pdustats"
 )
 
+// noDataLabel marks statistics for racks without usable samples (idle or unpopulated)
+const noDataLabel = "N/A"
+
 // PDUData holds the processed statistics for a PDU
+// Racks without data are stored as NaN.
 type PDUData struct {
 	PDUName string
 	L1Min   []float64 // Q1-Q18 values
@@ -45,54 +53,86 @@ type PDUData struct {
 	L3Min   []float64
 	L3Avg   []float64
 	L3Max   []float64
+
+	// Coincident per-rack summary computed by the parser; empty rule if absent
+	SummaryRule string
+	SummaryUnit string
+	SummaryMin  []float64
+	SummaryAvg  []float64
+	SummaryMax  []float64
+
+	DailyPeaks []pdustats.DailyPeak // per-phase daily peaks (JSON input only)
 }
 
 // PDUSection represents a PDU section in the template
 type PDUSection struct {
 	Name      string
 	HeaderRow int
-	StartRow  int // First Q1 row
-	EndRow    int // Last Q18 row
+	StartRow  int         // First row after the header
+	EndRow    int         // Last row before the next section (or sheet end)
+	RackRows  map[int]int // rack number (7 for "Q7") -> row index
+	Duplicate []int       // rows whose rack label was already used in the section
 }
 
 // MonthlyFiller handles filling PDU data into monthly template
 type MonthlyFiller struct {
-	pduData     PDUData
-	monthlyData [][]interface{}
-	pduSections []PDUSection
+	pduData         PDUData
+	monthlyData     [][]Cell
+	pduSections     []PDUSection
+	layout          *TemplateLayout
+	dryRun          bool         // fill in memory only and print the cell-level diff
+	showDiff        bool         // print a diff summary after writing
+	changes         []CellChange // cells changed by the last fill
+	onExisting      string       // policy for sections that already contain data
+	kept            int          // cells left untouched by the merge policy
+	templateFile    string       // file the report was loaded from
+	summaryFormulas bool         // write summary columns as Excel formulas
+	thresholds      *Thresholds  // highlight XLSX output when set
+	charts          bool         // add the Charts and Daily Peaks sheets to XLSX output
+	backups         int          // timestamped backups of the output to keep
+	lockTimeout     time.Duration
 }
 
-// NewMonthlyFiller creates a new filler instance
+// NewMonthlyFiller creates a new filler instance using the stock template layout
 func NewMonthlyFiller() *MonthlyFiller {
-	return &MonthlyFiller{}
+	return &MonthlyFiller{
+		layout:      DefaultLayout(),
+		onExisting:  OnExistingOverwrite,
+		backups:     5,
+		lockTimeout: defaultLockTimeout,
+	}
 }
 
 // ExtractPDUNameFromFilename extracts PDU name from filename like "total_a1.csv" -> "A1"
+// Only the parser's naming scheme is understood: "total_" followed by the PDU name and an
+// optional "_<suffix>", or a bare PDU name, so "total_d12.csv" and "total_a1_june.csv" work
+// but "report_backup2.csv" is rejected.
 func (mf *MonthlyFiller) ExtractPDUNameFromFilename(filename string) (string, error) {
 	basename := filepath.Base(filename)
 	basename = strings.TrimSuffix(basename, filepath.Ext(basename))
 
-	// Match patterns like "total_a1", "total_b2", "a3", "A1", etc.
-	re := regexp.MustCompile(`(?i)([abc]\d+)`)
+	// Match patterns like "total_a1", "total_b2_june", "a3", "A1", "total_hall-d7", etc.
+	re := regexp.MustCompile(`(?i)^(?:total_([a-z0-9][a-z0-9-]*)(?:_.*)?|([a-z0-9][a-z0-9-]*))$`)
 	matches := re.FindStringSubmatch(basename)
 
-	if len(matches) < 2 {
+	if len(matches) < 3 {
 		return "", fmt.Errorf("could not extract PDU name from filename: %s", filename)
 	}
 
-	pduName := strings.ToUpper(matches[1])
+	pduName := strings.ToUpper(matches[1] + matches[2]) // Only one of the groups matches
 	return pduName, nil
 }
 
-// LoadPDUData loads the processed PDU statistics from CSV
+// LoadPDUData loads the processed PDU statistics from a pdustats JSON file or a legacy CSV
 func (mf *MonthlyFiller) LoadPDUData(filename string) error {
-	// Extract PDU name from filename
-	pduName, err := mf.ExtractPDUNameFromFilename(filename)
-	if err != nil {
-		return err
+	if strings.EqualFold(filepath.Ext(filename), ".json") {
+		return mf.loadPDUStats(filename)
 	}
-	mf.pduData.PDUName = pduName
+	return mf.loadLegacyPDUData(filename)
+}
 
+// loadLegacyPDUData loads the positional total_xx.csv written by the parser
+func (mf *MonthlyFiller) loadLegacyPDUData(filename string) error {
 	file, err := os.Open(filename)
 	if err != nil {
 		return fmt.Errorf("failed to open PDU data file: %v", err)
@@ -100,6 +140,7 @@ func (mf *MonthlyFiller) LoadPDUData(filename string) error {
 	defer file.Close()
 
 	reader := csv.NewReader(file)
+	reader.FieldsPerRecord = -1
 	records, err := reader.ReadAll()
 	if err != nil {
 		return fmt.Errorf("failed to read CSV: %v", err)
@@ -110,19 +151,26 @@ func (mf *MonthlyFiller) LoadPDUData(filename string) error {
 	}
 
 	// Initialize slices for Q1-Q18 (18 values each)
-	mf.pduData.L1Min = make([]float64, 18)
-	mf.pduData.L1Avg = make([]float64, 18)
-	mf.pduData.L1Max = make([]float64, 18)
-	mf.pduData.L2Min = make([]float64, 18)
-	mf.pduData.L2Avg = make([]float64, 18)
-	mf.pduData.L2Max = make([]float64, 18)
-	mf.pduData.L3Min = make([]float64, 18)
-	mf.pduData.L3Avg = make([]float64, 18)
-	mf.pduData.L3Max = make([]float64, 18)
+	mf.pduData.reset(0)
 
 	// Parse each measurement type row
+	contentName := ""
 	for i := 1; i < len(records); i++ {
 		record := records[i]
+
+		// PDU name row written by newer parsers: "pdu,A1,..."
+		if len(record) >= 2 && strings.EqualFold(strings.TrimSpace(record[0]), pduNameLabel) {
+			contentName = strings.TrimSpace(record[1])
+			continue
+		}
+
+		// Summary rule row: "summary,sum,A,..."
+		if len(record) >= 3 && strings.EqualFold(strings.TrimSpace(record[0]), summaryRuleLabel) {
+			mf.pduData.SummaryRule = strings.TrimSpace(record[1])
+			mf.pduData.SummaryUnit = strings.TrimSpace(record[2])
+			continue
+		}
+
 		if len(record) < 19 { // Measurement Type + Q1-Q18
 			continue
 		}
@@ -130,34 +178,21 @@ func (mf *MonthlyFiller) LoadPDUData(filename string) error {
 		measurementType := strings.TrimSpace(record[0])
 
 		// Parse Q1-Q18 values (columns 1-18)
-		var targetSlice []float64
-		switch measurementType {
-		case "l1 min":
-			targetSlice = mf.pduData.L1Min
-		case "l1 avg":
-			targetSlice = mf.pduData.L1Avg
-		case "l1 max":
-			targetSlice = mf.pduData.L1Max
-		case "l2 min":
-			targetSlice = mf.pduData.L2Min
-		case "l2 avg":
-			targetSlice = mf.pduData.L2Avg
-		case "l2 max":
-			targetSlice = mf.pduData.L2Max
-		case "l3 min":
-			targetSlice = mf.pduData.L3Min
-		case "l3 avg":
-			targetSlice = mf.pduData.L3Avg
-		case "l3 max":
-			targetSlice = mf.pduData.L3Max
-		default:
+		targetSlice := mf.pduData.Series(measurementType)
+		if targetSlice == nil {
 			continue
 		}
 
 		// Fill the values for Q1-Q18
 		for j := 1; j < 19; j++ { // columns 1-18 (Q1-Q18)
 			if j < len(record) {
-				value, err := strconv.ParseFloat(strings.TrimSpace(record[j]), 64)
+				cell := strings.TrimSpace(record[j])
+				if strings.EqualFold(cell, noDataLabel) {
+					targetSlice[j-1] = math.NaN()
+					continue
+				}
+
+				value, err := strconv.ParseFloat(cell, 64)
 				if err == nil {
 					targetSlice[j-1] = value // j-1 because array is 0-indexed
 				}
@@ -165,24 +200,14 @@ func (mf *MonthlyFiller) LoadPDUData(filename string) error {
 		}
 	}
 
-	fmt.Printf("Loaded PDU %s data from %s\n", mf.pduData.PDUName, filename)
-	return nil
-}
-
-// loadExcelFile loads an Excel file and returns rows as string arrays
-func (mf *MonthlyFiller) loadExcelFile(filename string) ([][]string, error) {
-	f, err := excelize.OpenFile(filename)
+	pduName, err := mf.resolvePDUName(filename, contentName)
 	if err != nil {
-		return nil, err
-	}
-	defer f.Close()
-
-	sheets := f.GetSheetList()
-	if len(sheets) == 0 {
-		return nil, fmt.Errorf("no sheets found")
+		return err
 	}
+	mf.pduData.PDUName = pduName
 
-	return f.GetRows(sheets[0])
+	fmt.Printf("Loaded PDU %s data from %s\n", mf.pduData.PDUName, filename)
+	return nil
 }
 
 // loadCSVFile loads a CSV file and returns rows as string arrays
@@ -218,95 +243,103 @@ func (mf *MonthlyFiller) LoadMonthlyTemplate(filename string, preserveExisting b
 		targetFile = filename
 		fmt.Printf("Using clean template: %s\n", targetFile)
 	}
+	mf.templateFile = targetFile
 
-	// Handle different file formats
-	var rows [][]string
-	var err error
-
+	// Handle different file formats, keeping every cell's type and original text
 	if strings.HasSuffix(strings.ToLower(targetFile), ".csv") {
 		// Load CSV file (existing filled template)
-		rows, err = mf.loadCSVFile(targetFile)
+		rows, err := mf.loadCSVFile(targetFile)
 		if err != nil {
 			return fmt.Errorf("failed to load CSV file: %v", err)
 		}
+		mf.monthlyData = make([][]Cell, len(rows))
+		for i, row := range rows {
+			mf.monthlyData[i] = make([]Cell, len(row))
+			for j, text := range row {
+				mf.monthlyData[i][j] = textCell(text)
+			}
+		}
 	} else {
 		// Load Excel file (clean template)
-		rows, err = mf.loadExcelFile(targetFile)
+		cells, err := mf.loadExcelCells(targetFile)
 		if err != nil {
 			return fmt.Errorf("failed to load Excel file: %v", err)
 		}
+		mf.monthlyData = cells
 	}
 
-	// Convert to interface{} format
-	mf.monthlyData = make([][]interface{}, len(rows))
-	for i, row := range rows {
-		mf.monthlyData[i] = make([]interface{}, len(row))
-		for j, cell := range row {
-			// Try to parse as float first, then keep as string
-			if cell != "" {
-				if val, err := strconv.ParseFloat(cell, 64); err == nil {
-					mf.monthlyData[i][j] = val
-				} else {
-					mf.monthlyData[i][j] = cell
-				}
-			} else {
-				mf.monthlyData[i][j] = nil
-			}
-		}
-
-		// Ensure all rows have at least 15 columns (including summary columns)
-		for len(mf.monthlyData[i]) < 15 {
-			mf.monthlyData[i] = append(mf.monthlyData[i], nil)
-		}
+	// Identify all PDU sections, then resolve columns from headers above the first one
+	mf.identifyPDUSections()
+	headerRows := len(mf.monthlyData)
+	if len(mf.pduSections) > 0 {
+		headerRows = mf.pduSections[0].HeaderRow
 	}
+	mf.layout.LocateColumns(mf.monthlyData, headerRows)
 
-	// Identify all PDU sections
-	mf.identifyPDUSections()
+	// Ensure all rows have enough columns (including summary columns)
+	for i := range mf.monthlyData {
+		mf.padRow(i)
+	}
 
 	fmt.Printf("Loaded template from %s (%d rows, %d PDU sections)\n",
 		targetFile, len(mf.monthlyData), len(mf.pduSections))
 	return nil
 }
 
-// identifyPDUSections scans the template to find all PDU sections
+// identifyPDUSections scans the template to find all PDU sections and their rack rows
+// Each section runs from its "PDU X" marker to the row before the next marker, and
+// rack rows are matched by their label cell (e.g. "Q7") rather than by offset.
 func (mf *MonthlyFiller) identifyPDUSections() {
 	mf.pduSections = []PDUSection{}
 
 	for i := 0; i < len(mf.monthlyData); i++ {
-		if len(mf.monthlyData[i]) == 0 {
-			continue
-		}
+		if pduName, ok := mf.layout.SectionName(mf.labelCell(i)); ok {
+			// Found a PDU header; close the previous section
+			if n := len(mf.pduSections); n > 0 {
+				mf.pduSections[n-1].EndRow = i - 1
+			}
 
-		firstCell := mf.monthlyData[i][0]
-		if firstCell == nil {
-			continue
+			mf.pduSections = append(mf.pduSections, PDUSection{
+				Name:      pduName,
+				HeaderRow: i,
+				StartRow:  i + 1,
+				EndRow:    len(mf.monthlyData) - 1,
+			})
 		}
+	}
 
-		cellStr := fmt.Sprintf("%v", firstCell)
-		if strings.HasPrefix(cellStr, "PDU ") {
-			// Found a PDU header
-			pduName := strings.TrimPrefix(cellStr, "PDU ")
-			startRow := i + 1       // Q1 starts on next row
-			endRow := startRow + 17 // Q1-Q18 = 18 rows
+	for i := range mf.pduSections {
+		section := &mf.pduSections[i]
+		section.RackRows = make(map[int]int)
 
-			section := PDUSection{
-				Name:      pduName,
-				HeaderRow: i,
-				StartRow:  startRow,
-				EndRow:    endRow,
+		for row := section.StartRow; row <= section.EndRow; row++ {
+			rack, ok := mf.layout.RackNumber(mf.labelCell(row))
+			if !ok {
+				continue
 			}
-			mf.pduSections = append(mf.pduSections, section)
-
-			fmt.Printf("Found PDU %s: header at row %d, data rows %d-%d\n",
-				pduName, section.HeaderRow, section.StartRow, section.EndRow)
+			if previous, dup := section.RackRows[rack]; dup {
+				fmt.Printf("⚠️  PDU %s: rack Q%d appears on rows %d and %d, using row %d\n",
+					section.Name, rack, previous, row, previous)
+				section.Duplicate = append(section.Duplicate, row)
+				continue
+			}
+			section.RackRows[rack] = row
 		}
+
+		fmt.Printf("Found PDU %s: header at row %d, data rows %d-%d (%d rack rows)\n",
+			section.Name, section.HeaderRow, section.StartRow, section.EndRow, len(section.RackRows))
 	}
 }
 
+// labelCell returns the text of a row's label cell, or "" if it is empty
+func (mf *MonthlyFiller) labelCell(rowIndex int) string {
+	return cellAt(mf.monthlyData[rowIndex], mf.layout.LabelColumn).Text
+}
+
 // FindPDUSection finds the section for the specified PDU name
 func (mf *MonthlyFiller) FindPDUSection(pduName string) (*PDUSection, error) {
 	for i := range mf.pduSections {
-		if mf.pduSections[i].Name == pduName {
+		if strings.EqualFold(mf.pduSections[i].Name, pduName) {
 			return &mf.pduSections[i], nil
 		}
 	}
@@ -323,36 +356,31 @@ func (mf *MonthlyFiller) FillPDUData() error {
 
 	fmt.Printf("Filling data into PDU %s section (rows %d-%d)\n",
 		section.Name, section.StartRow, section.EndRow)
+	if mf.pduData.SummaryRule != "" {
+		fmt.Printf("Summary per rack: %s of time-aligned phases (%s), computed by the parser\n",
+			mf.pduData.SummaryRule, mf.pduData.SummaryUnit)
+		if mf.summaryFormulas {
+			fmt.Printf("⚠️  --summary-formulas ignored: the %s summary cannot be derived from the phase cells\n",
+				mf.pduData.SummaryRule)
+		}
+	}
 
-	// Column mapping based on the template structure
-	columnMapping := map[string]int{
-		"L1Min": 3,  // Current L1 Min
-		"L1Avg": 4,  // Current L1 AVG
-		"L1Max": 5,  // Current L1 Max
-		"L2Min": 6,  // Current L2 Min
-		"L2Avg": 7,  // Current L2 AVG
-		"L2Max": 8,  // Current L2 Max
-		"L3Min": 9,  // Current L3 Min
-		"L3Avg": 10, // Current L3 AVG
-		"L3Max": 11, // Current L3 Max
-		// Summary per Rack columns
-		"SummaryMin": 12, // Current Min (summary)
-		"SummaryAvg": 13, // Current AVG (summary)
-		"SummaryMax": 14, // Current Max (summary)
-	}
-
-	// Fill data for Q1-Q18
-	for q := 0; q < 18; q++ {
-		rowIndex := section.StartRow + q // Q1 at StartRow, Q2 at StartRow+1, etc.
+	// Column mapping from the template layout (L1Min ... SummaryMax)
+	columnMapping := mf.layout.ColumnMapping()
 
-		if rowIndex >= len(mf.monthlyData) {
-			return fmt.Errorf("row index %d exceeds template size", rowIndex)
+	// Fill data for Q1-Q18 into the rows labelled with each rack
+	var missingInTemplate []string
+	for q := 0; q < 18; q++ {
+		rowIndex, ok := section.RackRows[q+1]
+		if !ok {
+			if mf.pduData.HasRack(q) {
+				missingInTemplate = append(missingInTemplate, fmt.Sprintf("Q%d", q+1))
+			}
+			continue
 		}
 
 		// Ensure row has enough columns
-		for len(mf.monthlyData[rowIndex]) < 15 {
-			mf.monthlyData[rowIndex] = append(mf.monthlyData[rowIndex], nil)
-		}
+		mf.padRow(rowIndex)
 
 		// Fill L1, L2, L3 data
 		l1Min := mf.pduData.L1Min[q]
@@ -365,30 +393,48 @@ func (mf *MonthlyFiller) FillPDUData() error {
 		l3Avg := mf.pduData.L3Avg[q]
 		l3Max := mf.pduData.L3Max[q]
 
-		mf.monthlyData[rowIndex][columnMapping["L1Min"]] = l1Min
-		mf.monthlyData[rowIndex][columnMapping["L1Avg"]] = l1Avg
-		mf.monthlyData[rowIndex][columnMapping["L1Max"]] = l1Max
-		mf.monthlyData[rowIndex][columnMapping["L2Min"]] = l2Min
-		mf.monthlyData[rowIndex][columnMapping["L2Avg"]] = l2Avg
-		mf.monthlyData[rowIndex][columnMapping["L2Max"]] = l2Max
-		mf.monthlyData[rowIndex][columnMapping["L3Min"]] = l3Min
-		mf.monthlyData[rowIndex][columnMapping["L3Avg"]] = l3Avg
-		mf.monthlyData[rowIndex][columnMapping["L3Max"]] = l3Max
+		mf.setMetric(rowIndex, columnMapping["L1Min"], l1Min)
+		mf.setMetric(rowIndex, columnMapping["L1Avg"], l1Avg)
+		mf.setMetric(rowIndex, columnMapping["L1Max"], l1Max)
+		mf.setMetric(rowIndex, columnMapping["L2Min"], l2Min)
+		mf.setMetric(rowIndex, columnMapping["L2Avg"], l2Avg)
+		mf.setMetric(rowIndex, columnMapping["L2Max"], l2Max)
+		mf.setMetric(rowIndex, columnMapping["L3Min"], l3Min)
+		mf.setMetric(rowIndex, columnMapping["L3Avg"], l3Avg)
+		mf.setMetric(rowIndex, columnMapping["L3Max"], l3Max)
 
 		// Calculate summary per rack
-		// Current Min = minimum of all min values (L1, L2, L3)
-		summaryMin := mf.minValue(l1Min, l2Min, l3Min)
-
-		// Current AVG = average of all avg values (L1, L2, L3)
-		summaryAvg := (l1Avg + l2Avg + l3Avg) / 3.0
-
-		// Current Max = maximum of all max values (L1, L2, L3)
-		summaryMax := mf.maxValue(l1Max, l2Max, l3Max)
+		summaryMin, summaryAvg, summaryMax := mf.summaryValues(q)
+
+		// Fill summary columns, optionally as formulas over the phase cells
+		mf.setMetric(rowIndex, columnMapping["SummaryMin"], summaryMin)
+		mf.setMetric(rowIndex, columnMapping["SummaryAvg"], summaryAvg)
+		mf.setMetric(rowIndex, columnMapping["SummaryMax"], summaryMax)
+		if mf.summaryFormulas && mf.pduData.SummaryRule == "" {
+			mf.setFormula(rowIndex, columnMapping["SummaryMin"], summaryFormula("MIN", rowIndex,
+				columnMapping["L1Min"], columnMapping["L2Min"], columnMapping["L3Min"]))
+			mf.setFormula(rowIndex, columnMapping["SummaryAvg"], summaryFormula("AVERAGE", rowIndex,
+				columnMapping["L1Avg"], columnMapping["L2Avg"], columnMapping["L3Avg"]))
+			mf.setFormula(rowIndex, columnMapping["SummaryMax"], summaryFormula("MAX", rowIndex,
+				columnMapping["L1Max"], columnMapping["L2Max"], columnMapping["L3Max"]))
+		}
+	}
 
-		// Fill summary columns
-		mf.monthlyData[rowIndex][columnMapping["SummaryMin"]] = summaryMin
-		mf.monthlyData[rowIndex][columnMapping["SummaryAvg"]] = summaryAvg
-		mf.monthlyData[rowIndex][columnMapping["SummaryMax"]] = summaryMax
+	// Report racks that could not be matched in either direction
+	if len(missingInTemplate) > 0 {
+		fmt.Printf("⚠️  PDU %s: racks with data but no row in the template: %s\n",
+			section.Name, strings.Join(missingInTemplate, ", "))
+	}
+	var missingInData []string
+	for rack := range section.RackRows {
+		if rack < 1 || rack > 18 || !mf.pduData.HasRack(rack-1) {
+			missingInData = append(missingInData, fmt.Sprintf("Q%d", rack))
+		}
+	}
+	if len(missingInData) > 0 {
+		sort.Slice(missingInData, func(i, j int) bool { return rackLess(missingInData[i], missingInData[j]) })
+		fmt.Printf("⚠️  PDU %s: template rows without data: %s\n",
+			section.Name, strings.Join(missingInData, ", "))
 	}
 
 	fmt.Printf("Successfully filled %s data into PDU %s section (including summary calculations)\n",
@@ -396,59 +442,131 @@ func (mf *MonthlyFiller) FillPDUData() error {
 	return nil
 }
 
-// minValue returns the minimum of three float64 values
-func (mf *MonthlyFiller) minValue(a, b, c float64) float64 {
-	min := a
-	if b < min {
-		min = b
+// summaryValues returns the summary min, avg and max of the rack at index q (0 = Q1).
+// A summary computed by the parser from time-aligned phases takes precedence;
+// otherwise phases without data are ignored.
+func (mf *MonthlyFiller) summaryValues(q int) (float64, float64, float64) {
+	d := &mf.pduData
+	if d.SummaryRule != "" {
+		return d.SummaryMin[q], d.SummaryAvg[q], d.SummaryMax[q]
+	}
+
+	// Current Min = minimum of all min values (L1, L2, L3)
+	// Current AVG = average of all avg values (L1, L2, L3)
+	// Current Max = maximum of all max values (L1, L2, L3)
+	return mf.minValue(d.L1Min[q], d.L2Min[q], d.L3Min[q]),
+		mf.avgValue(d.L1Avg[q], d.L2Avg[q], d.L3Avg[q]),
+		mf.maxValue(d.L1Max[q], d.L2Max[q], d.L3Max[q])
+}
+
+// setMetric writes a statistic into a template cell
+// With the merge policy, cells that already hold a statistic are kept; "N/A" is refilled.
+func (mf *MonthlyFiller) setMetric(rowIndex, col int, v float64) {
+	if mf.onExisting == OnExistingMerge && mf.monthlyData[rowIndex][col].holdsStatistic() {
+		mf.kept++
+		return
 	}
-	if c < min {
-		min = c
+	mf.monthlyData[rowIndex][col] = metricCell(v)
+}
+
+// setFormula turns a cell just written by setMetric into a formula cell, keeping
+// the computed value as its text for CSV output
+func (mf *MonthlyFiller) setFormula(rowIndex, col int, formula string) {
+	cell := &mf.monthlyData[rowIndex][col]
+	if !cell.Written {
+		return // Kept by the merge policy
+	}
+	cell.Kind = CellFormula
+	cell.Formula = formula
+}
+
+// padRow extends a template row to the width required by the layout
+func (mf *MonthlyFiller) padRow(rowIndex int) {
+	for len(mf.monthlyData[rowIndex]) < mf.layout.Width() {
+		mf.monthlyData[rowIndex] = append(mf.monthlyData[rowIndex], Cell{})
 	}
-	return min
 }
 
-// maxValue returns the maximum of three float64 values
-func (mf *MonthlyFiller) maxValue(a, b, c float64) float64 {
-	max := a
-	if b > max {
-		max = b
+// minValue returns the minimum of the values, skipping NaN (no data)
+// Returns NaN if no value is available.
+func (mf *MonthlyFiller) minValue(values ...float64) float64 {
+	min := math.NaN()
+	for _, v := range values {
+		if math.IsNaN(v) {
+			continue
+		}
+		if math.IsNaN(min) || v < min {
+			min = v
+		}
 	}
-	if c > max {
-		max = c
+	return min
+}
+
+// maxValue returns the maximum of the values, skipping NaN (no data)
+// Returns NaN if no value is available.
+func (mf *MonthlyFiller) maxValue(values ...float64) float64 {
+	max := math.NaN()
+	for _, v := range values {
+		if math.IsNaN(v) {
+			continue
+		}
+		if math.IsNaN(max) || v > max {
+			max = v
+		}
 	}
 	return max
 }
 
-// ExportToCSV exports the filled template to CSV format
-func (mf *MonthlyFiller) ExportToCSV(filename string) error {
-	file, err := os.Create(filename)
+// avgValue returns the mean of the values, skipping NaN (no data)
+// Returns NaN if no value is available.
+func (mf *MonthlyFiller) avgValue(values ...float64) float64 {
+	sum := 0.0
+	count := 0
+	for _, v := range values {
+		if math.IsNaN(v) {
+			continue
+		}
+		sum += v
+		count++
+	}
+	if count == 0 {
+		return math.NaN()
+	}
+	return sum / float64(count)
+}
+
+// Export writes the filled report as CSV, or as a workbook if filename ends in .xlsx
+// The previous file is backed up first, and the new one is written atomically.
+func (mf *MonthlyFiller) Export(filename string) error {
+	backupName, err := backupFile(filename, mf.backups)
 	if err != nil {
-		return fmt.Errorf("failed to create output file: %v", err)
+		return err
+	}
+	if backupName != "" {
+		fmt.Printf("Backed up previous report to %s\n", backupName)
+	}
+
+	write := mf.writeCSV
+	if isXLSX(filename) {
+		write = mf.writeXLSX
+	}
+	if err := writeFileAtomic(filename, write); err != nil {
+		return err
 	}
-	defer file.Close()
 
-	writer := csv.NewWriter(file)
-	defer writer.Flush()
+	fmt.Printf("Exported filled data to %s\n", filename)
+	return nil
+}
 
-	// Convert interface{} data to strings and write to CSV
+// writeCSV writes the filled report as CSV; formulas are written as their values
+func (mf *MonthlyFiller) writeCSV(w io.Writer) error {
+	writer := csv.NewWriter(w)
+
+	// Untouched cells are written exactly as they were read
 	for _, row := range mf.monthlyData {
 		record := make([]string, len(row))
 		for i, cell := range row {
-			if cell == nil {
-				record[i] = ""
-			} else {
-				switch v := cell.(type) {
-				case string:
-					record[i] = v
-				case float64:
-					record[i] = fmt.Sprintf("%.3f", v)
-				case int:
-					record[i] = fmt.Sprintf("%d", v)
-				default:
-					record[i] = fmt.Sprintf("%v", v)
-				}
-			}
+			record[i] = cell.Text
 		}
 
 		if err := writer.Write(record); err != nil {
@@ -456,12 +574,23 @@ func (mf *MonthlyFiller) ExportToCSV(filename string) error {
 		}
 	}
 
-	fmt.Printf("Exported filled data to %s\n", filename)
+	writer.Flush()
+	if err := writer.Error(); err != nil {
+		return fmt.Errorf("failed to flush CSV: %v", err)
+	}
 	return nil
 }
 
 // ProcessFiles is the main processing function
 func (mf *MonthlyFiller) ProcessFiles(pduDataFile, monthlyFile, outputFile string, preserveExisting bool) error {
+	// Hold the report lock for the whole load-fill-export cycle so concurrent
+	// runs for other PDUs don't overwrite each other's sections
+	lock, err := acquireLock(outputFile, mf.lockTimeout)
+	if err != nil {
+		return err
+	}
+	defer lock.Release()
+
 	// Load PDU data
 	if err := mf.LoadPDUData(pduDataFile); err != nil {
 		return fmt.Errorf("error loading PDU data: %v", err)
@@ -478,51 +607,105 @@ func (mf *MonthlyFiller) ProcessFiles(pduDataFile, monthlyFile, outputFile strin
 		return fmt.Errorf("error finding PDU section: %v", err)
 	}
 
-	// Check if this section already has data
-	hasExistingData := false
-	if section.StartRow < len(mf.monthlyData) {
-		firstDataRow := mf.monthlyData[section.StartRow]
-		if len(firstDataRow) > 3 && firstDataRow[3] != nil {
-			hasExistingData = true
+	// Apply the policy for sections that already contain data
+	if filled := mf.filledCells(section); filled > 0 {
+		switch mf.onExisting {
+		case OnExistingFail:
+			return fmt.Errorf("PDU %s section already contains data (%d cells); use --on-existing=overwrite or merge",
+				mf.pduData.PDUName, filled)
+		case OnExistingSkip:
+			fmt.Printf("⏭️  PDU %s section already contains data (%d cells) - skipped, %s left unchanged\n",
+				mf.pduData.PDUName, filled, outputFile)
+			return nil
+		case OnExistingMerge:
+			fmt.Printf("PDU %s section already contains data (%d cells) - only empty cells will be filled\n",
+				mf.pduData.PDUName, filled)
+		default:
+			fmt.Printf("⚠️  PDU %s section already contains data (%d cells) - it will be overwritten\n",
+				mf.pduData.PDUName, filled)
 		}
 	}
 
-	if hasExistingData {
-		fmt.Printf("⚠️  PDU %s section already contains data - it will be overwritten\n", mf.pduData.PDUName)
-	}
-
-	// Fill PDU data into template
+	// Fill PDU data into template, keeping the previous values for the diff
+	before := mf.snapshotSection(section)
 	if err := mf.FillPDUData(); err != nil {
 		return fmt.Errorf("error filling PDU data: %v", err)
 	}
+	mf.changes = mf.DiffSection(section, before)
+	if mf.kept > 0 {
+		fmt.Printf("Merge: kept %d existing cells in PDU %s\n", mf.kept, mf.pduData.PDUName)
+	}
+
+	if mf.dryRun {
+		PrintDiff(mf.changes, true)
+		fmt.Printf("Dry run: %s was not written\n", outputFile)
+		return nil
+	}
+
+	// Export to CSV or XLSX
+	if err := mf.Export(outputFile); err != nil {
+		return fmt.Errorf("error exporting report: %v", err)
+	}
 
-	// Export to CSV
-	if err := mf.ExportToCSV(outputFile); err != nil {
-		return fmt.Errorf("error exporting to CSV: %v", err)
+	if mf.showDiff {
+		PrintDiff(mf.changes, false)
 	}
 
 	return nil
 }
 
 func main() {
+	// Subcommands
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "validate-template":
+			os.Exit(runValidateTemplate(os.Args[2:]))
+		case "generate-template":
+			os.Exit(runGenerateTemplate(os.Args[2:]))
+		case "compare":
+			os.Exit(runCompare(os.Args[2:]))
+		case "history":
+			os.Exit(runHistory(os.Args[2:]))
+		}
+	}
+
 	// Check command line arguments
 	if len(os.Args) < 2 {
 		fmt.Printf("Usage: %s <pdu_data_file> [options]\n", os.Args[0])
 		fmt.Printf("\nOptions:\n")
 		fmt.Printf("  -t, --template <file>    Monthly template file (default: monthlyjune2025.xlsx)\n")
-		fmt.Printf("  -o, --output <file>      Output file (default: filled_monthly_report.csv)\n")
+		fmt.Printf("  -o, --output <file>      Output file, .csv or .xlsx (default: filled_monthly_report.csv)\n")
 		fmt.Printf("  -c, --clean              Use clean template (don't preserve existing data)\n")
 		fmt.Printf("  -p, --preserve           Preserve existing data (default behavior)\n")
+		fmt.Printf("  -l, --layout <file>      Template layout descriptor (JSON) for customised templates\n")
+		fmt.Printf("  -n, --dry-run            Fill in memory and print a cell-level diff without writing\n")
+		fmt.Printf("  -d, --diff               Print a summary of changed cells after writing\n")
+		fmt.Printf("      --on-existing <p>    If the PDU section has data: overwrite (default), skip, fail, merge\n")
+		fmt.Printf("                           merge only fills empty cells, keeping hand-corrected values\n")
+		fmt.Printf("      --summary-formulas   Write Current Min/AVG/Max as Excel formulas over the L1-L3 cells\n")
+		fmt.Printf("      --highlight          Colour XLSX cells by breaker load and add a Legend sheet\n")
+		fmt.Printf("      --thresholds <file>  Highlight thresholds (JSON); implies --highlight\n")
+		fmt.Printf("      --charts             Add per-PDU rack and daily peak charts to .xlsx output\n")
+		fmt.Printf("      --backups <n>        Timestamped backups of the output to keep (default: 5, 0 disables)\n")
+		fmt.Printf("      --lock-timeout <d>   How long to wait for another run on the same report (default: 30s)\n")
+		fmt.Printf("\nCommands:\n")
+		fmt.Printf("  %s validate-template <template> [-l layout]  # Check a template before month-end\n", os.Args[0])
+		fmt.Printf("  %s generate-template -i inventory.csv -m 2025-07  # Build a fresh monthly template\n", os.Args[0])
+		fmt.Printf("  %s compare june.csv july.csv -o diff.xlsx    # Month-over-month deltas\n", os.Args[0])
+		fmt.Printf("  %s history import total_*.csv -m 2025-06    # Store past months for trend/YTD reports\n", os.Args[0])
+		fmt.Printf("  %s history ytd --year 2025 -o ytd.csv       # Year-to-date min/avg/peak per rack phase\n", os.Args[0])
+		fmt.Printf("  %s history forecast -o forecast.csv         # When each rack phase reaches capacity\n", os.Args[0])
 		fmt.Printf("\nExamples:\n")
 		fmt.Printf("  %s total_a1.csv                              # First PDU - creates new report\n", os.Args[0])
 		fmt.Printf("  %s total_a2.csv                              # Second PDU - adds to existing report\n", os.Args[0])
 		fmt.Printf("  %s total_a3.csv -o custom_report.csv         # Custom output file\n", os.Args[0])
 		fmt.Printf("  %s total_a1.csv --clean                      # Force clean template\n", os.Args[0])
+		fmt.Printf("  %s total_a1.csv --dry-run                    # Review changes before writing\n", os.Args[0])
 		fmt.Printf("\nWorkflow for multiple PDUs:\n")
 		fmt.Printf("  %s total_a1.csv                              # Creates filled_monthly_report.csv with A1 data\n", os.Args[0])
 		fmt.Printf("  %s total_a2.csv                              # Adds A2 data, keeps A1 data\n", os.Args[0])
 		fmt.Printf("  %s total_b1.csv                              # Adds B1 data, keeps A1+A2 data\n", os.Args[0])
-		fmt.Printf("\nSupported PDU files: total_a1.csv, total_a2.csv, total_b1.csv, etc.\n")
+		fmt.Printf("\nSupported PDU files: total_a1.json (pdu-parser --format json), or legacy total_a1.csv, total_b1.csv, etc.\n")
 		os.Exit(1)
 	}
 
@@ -531,6 +714,16 @@ func main() {
 	monthlyFile := "monthlyjune2025.xlsx"     // Default template
 	outputFile := "filled_monthly_report.csv" // Default output
 	preserveExisting := true                  // Default: preserve existing data
+	layoutFile := ""                          // Default: built-in layout
+	dryRun := false
+	showDiff := false
+	onExisting := OnExistingOverwrite
+	backups := 5
+	summaryFormulas := false
+	highlight := false
+	charts := false
+	thresholdsFile := ""
+	lockTimeout := defaultLockTimeout
 
 	// Parse optional arguments
 	for i := 2; i < len(os.Args); i++ {
@@ -550,7 +743,57 @@ func main() {
 			preserveExisting = false
 		case "-p", "--preserve":
 			preserveExisting = true
+		case "-l", "--layout":
+			if i+1 < len(os.Args) {
+				layoutFile = os.Args[i+1]
+				i++ // Skip next argument
+			}
+		case "-n", "--dry-run":
+			dryRun = true
+		case "-d", "--diff":
+			showDiff = true
+		case "--summary-formulas":
+			summaryFormulas = true
+		case "--charts":
+			charts = true
+		case "--highlight":
+			highlight = true
+		case "--thresholds":
+			if i+1 < len(os.Args) {
+				thresholdsFile = os.Args[i+1]
+				highlight = true
+				i++ // Skip next argument
+			}
+		case "--backups":
+			if i+1 < len(os.Args) {
+				n, err := strconv.Atoi(os.Args[i+1])
+				if err != nil || n < 0 {
+					log.Fatalf("Invalid --backups value %q", os.Args[i+1])
+				}
+				backups = n
+				i++ // Skip next argument
+			}
+		case "--lock-timeout":
+			if i+1 < len(os.Args) {
+				d, err := time.ParseDuration(os.Args[i+1])
+				if err != nil || d < 0 {
+					log.Fatalf("Invalid --lock-timeout value %q (e.g. 30s, 2m)", os.Args[i+1])
+				}
+				lockTimeout = d
+				i++ // Skip next argument
+			}
+		case "--on-existing":
+			if i+1 < len(os.Args) {
+				onExisting = os.Args[i+1]
+				i++ // Skip next argument
+			}
 		default:
+			// --on-existing=<policy>
+			if strings.HasPrefix(arg, "--on-existing=") {
+				onExisting = strings.TrimPrefix(arg, "--on-existing=")
+				continue
+			}
+
 			// Assume it's a positional argument for backward compatibility
 			if i == 2 && !strings.HasPrefix(arg, "-") {
 				monthlyFile = arg
@@ -571,10 +814,44 @@ func main() {
 
 	// Create filler and process
 	filler := NewMonthlyFiller()
+	if layoutFile != "" {
+		layout, err := LoadLayout(layoutFile)
+		if err != nil {
+			log.Fatalf("Loading layout failed: %v", err)
+		}
+		filler.layout = layout
+	}
+	if thresholdsFile != "" {
+		thresholds, err := LoadThresholds(thresholdsFile)
+		if err != nil {
+			log.Fatalf("Loading thresholds failed: %v", err)
+		}
+		filler.thresholds = thresholds
+	} else if highlight {
+		filler.thresholds = DefaultThresholds()
+	}
+	if (highlight || charts) && !isXLSX(outputFile) {
+		fmt.Printf("⚠️  Highlighting and charts only apply to .xlsx output; %s is written without them\n", outputFile)
+	}
+	filler.charts = charts
+	if !validOnExisting(onExisting) {
+		log.Fatalf("Invalid --on-existing policy %q (use overwrite, skip, fail or merge)", onExisting)
+	}
+	filler.onExisting = onExisting
+	filler.backups = backups
+	filler.summaryFormulas = summaryFormulas
+	filler.lockTimeout = lockTimeout
+	filler.dryRun = dryRun
+	filler.showDiff = showDiff
 	if err := filler.ProcessFiles(pduDataFile, monthlyFile, outputFile, preserveExisting); err != nil {
 		log.Fatalf("Processing failed: %v", err)
 	}
 
+	if dryRun {
+		fmt.Printf("\n=== Dry run completed: %d cells would change in PDU %s ===\n", len(filler.changes), filler.pduData.PDUName)
+		return
+	}
+
 	fmt.Printf("\n=== Processing completed successfully! ===\n")
 	fmt.Printf("PDU Data Source: %s\n", pduDataFile)
 	fmt.Printf("Monthly Template: %s\n", monthlyFile)
@@ -587,9 +864,15 @@ func main() {
 		fmt.Printf("   Run: %s total_<next_pdu>.csv\n", os.Args[0])
 		fmt.Println("   This will add more PDU data while keeping existing sections intact.")
 		fmt.Println("\n📊 Summary per Rack columns now filled with:")
-		fmt.Println("   - Current Min: Minimum across L1/L2/L3 min values")
-		fmt.Println("   - Current AVG: Average across L1/L2/L3 avg values")
-		fmt.Println("   - Current Max: Maximum across L1/L2/L3 max values")
+		if rule := filler.pduData.SummaryRule; rule != "" {
+			fmt.Printf("   - Current Min/AVG/Max: Parser's %s summary of time-aligned L1/L2/L3 readings (%s)\n",
+				rule, filler.pduData.SummaryUnit)
+		} else {
+			fmt.Println("   - Current Min: Minimum across L1/L2/L3 min values")
+			fmt.Println("   - Current AVG: Average across L1/L2/L3 avg values")
+			fmt.Println("   - Current Max: Maximum across L1/L2/L3 max values")
+		}
+		fmt.Println("   - Racks without data (idle/unpopulated) are marked N/A")
 	} else {
 		fmt.Printf("Mode: Clean template (previous data erased) ⚠️\n")
 	}
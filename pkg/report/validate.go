@@ -0,0 +1,185 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+)
+
+// ValidateTemplate checks a loaded template against the layout and returns the problems found
+func (mf *MonthlyFiller) ValidateTemplate() []string {
+	var problems []string
+
+	if len(mf.pduSections) == 0 {
+		return append(problems, "no PDU sections found")
+	}
+
+	// Rack labels outside any section
+	for row := 0; row < mf.pduSections[0].HeaderRow; row++ {
+		if rack, ok := mf.layout.RackNumber(mf.labelCell(row)); ok {
+			problems = append(problems, fmt.Sprintf("row %d: rack label Q%d before the first PDU section", row, rack))
+		}
+	}
+
+	seen := make(map[string]int) // PDU name -> header row
+	for _, section := range mf.pduSections {
+		key := strings.ToUpper(section.Name)
+		if previous, dup := seen[key]; dup {
+			problems = append(problems, fmt.Sprintf("PDU %s: duplicate header on rows %d and %d", section.Name, previous, section.HeaderRow))
+		} else {
+			seen[key] = section.HeaderRow
+		}
+
+		// Sections too short for their racks either run past the sheet end or into the next section
+		if rows := section.EndRow - section.StartRow + 1; rows < mf.layout.RacksPerSection {
+			if section.EndRow == len(mf.monthlyData)-1 {
+				problems = append(problems, fmt.Sprintf("PDU %s: truncated, %d racks would run past the sheet end (row %d)",
+					section.Name, mf.layout.RacksPerSection, len(mf.monthlyData)-1))
+			} else {
+				problems = append(problems, fmt.Sprintf("PDU %s: only %d rows before the next section, %d racks expected (overlap)",
+					section.Name, rows, mf.layout.RacksPerSection))
+			}
+		}
+
+		var missing []string
+		for rack := 1; rack <= mf.layout.RacksPerSection; rack++ {
+			if _, ok := section.RackRows[rack]; !ok {
+				missing = append(missing, fmt.Sprintf("Q%d", rack))
+			}
+		}
+		if len(missing) > 0 {
+			problems = append(problems, fmt.Sprintf("PDU %s: missing rack rows %s", section.Name, strings.Join(missing, ", ")))
+		}
+
+		var unexpected []int
+		for rack := range section.RackRows {
+			if rack < 1 || rack > mf.layout.RacksPerSection {
+				unexpected = append(unexpected, rack)
+			}
+		}
+		sort.Ints(unexpected)
+		for _, rack := range unexpected {
+			problems = append(problems, fmt.Sprintf("PDU %s: unexpected rack row Q%d on row %d", section.Name, rack, section.RackRows[rack]))
+		}
+
+		for _, row := range section.Duplicate {
+			problems = append(problems, fmt.Sprintf("PDU %s: duplicate rack label %q on row %d", section.Name, mf.labelCell(row), row))
+		}
+	}
+
+	problems = append(problems, mf.validateColumns()...)
+	return problems
+}
+
+// validateColumns checks that metric columns are distinct and, when the template has
+// header cells, that every expected header was found
+func (mf *MonthlyFiller) validateColumns() []string {
+	var problems []string
+	mapping := mf.layout.ColumnMapping()
+
+	byColumn := make(map[int][]string)
+	for _, key := range layoutColumns {
+		byColumn[mapping[key]] = append(byColumn[mapping[key]], key)
+	}
+	columns := make([]int, 0, len(byColumn))
+	for col := range byColumn {
+		columns = append(columns, col)
+	}
+	sort.Ints(columns)
+	for _, col := range columns {
+		if keys := byColumn[col]; len(keys) > 1 {
+			problems = append(problems, fmt.Sprintf("column %d is used for %s", col, strings.Join(keys, ", ")))
+		}
+		if col == mf.layout.LabelColumn {
+			problems = append(problems, fmt.Sprintf("column %d (%s) is the label column", col, strings.Join(byColumn[col], ", ")))
+		}
+	}
+
+	// Only templates that carry a header row can be checked for missing headers
+	if len(mf.layout.located) > 0 {
+		for _, key := range layoutColumns {
+			if _, declared := mf.layout.headerRe[key]; declared && !mf.layout.located[key] {
+				problems = append(problems, fmt.Sprintf("missing expected column %s (no header matching %q)", key, mf.layout.Columns[key].Header))
+			}
+		}
+	}
+
+	return problems
+}
+
+// runValidateTemplate implements "validate-template <template> [-l layout]" and returns the exit code
+func runValidateTemplate(args []string) int {
+	if len(args) < 1 {
+		fmt.Println("Usage: monthly-filler validate-template <template> [-l layout.json]")
+		return 2
+	}
+
+	templateFile := args[0]
+	filler := NewMonthlyFiller()
+	for i := 1; i < len(args); i++ {
+		switch args[i] {
+		case "-l", "--layout":
+			if i+1 < len(args) {
+				layout, err := LoadLayout(args[i+1])
+				if err != nil {
+					log.Printf("Loading layout failed: %v", err)
+					return 2
+				}
+				filler.layout = layout
+				i++ // Skip next argument
+			}
+		}
+	}
+
+	if err := filler.LoadMonthlyTemplate(templateFile, false, ""); err != nil {
+		log.Printf("Validation failed: %v", err)
+		return 2
+	}
+
+	fmt.Printf("\n=== Template %s ===\n", templateFile)
+	for _, section := range filler.pduSections {
+		fmt.Printf("PDU %-8s header row %-4d rows %d-%d, racks %s\n",
+			section.Name, section.HeaderRow, section.StartRow, section.EndRow, describeRackRows(section.RackRows))
+	}
+	fmt.Printf("Columns: %s\n", filler.layout.Describe())
+
+	problems := filler.ValidateTemplate()
+	if len(problems) == 0 {
+		fmt.Printf("\n✅ Template is compatible (%d PDU sections)\n", len(filler.pduSections))
+		return 0
+	}
+
+	fmt.Printf("\n❌ %d problems found:\n", len(problems))
+	for _, problem := range problems {
+		fmt.Printf("  - %s\n", problem)
+	}
+	return 1
+}
+
+// describeRackRows summarises rack labels as a compact range like "Q1-Q18"
+func describeRackRows(rackRows map[int]int) string {
+	racks := make([]int, 0, len(rackRows))
+	for rack := range rackRows {
+		racks = append(racks, rack)
+	}
+	sort.Ints(racks)
+
+	var parts []string
+	for i := 0; i < len(racks); {
+		j := i
+		for j+1 < len(racks) && racks[j+1] == racks[j]+1 {
+			j++
+		}
+		if i == j {
+			parts = append(parts, fmt.Sprintf("Q%d", racks[i]))
+		} else {
+			parts = append(parts, fmt.Sprintf("Q%d-Q%d", racks[i], racks[j]))
+		}
+		i = j + 1
+	}
+	if len(parts) == 0 {
+		return "none"
+	}
+	return strings.Join(parts, ",")
+}
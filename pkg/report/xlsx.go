@@ -0,0 +1,108 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/xuri/excelize/v2"
+)
+
+// isXLSX reports whether a file name refers to an Excel workbook
+func isXLSX(filename string) bool {
+	lower := strings.ToLower(filename)
+	return strings.HasSuffix(lower, ".xlsx") || strings.HasSuffix(lower, ".xlsm")
+}
+
+// writeXLSX writes the filled report as a workbook. When the report was loaded from
+// a workbook, that workbook is reused and only the cells the filler wrote are set,
+// so formulas, styles, merged cells and other sheets survive unchanged.
+func (mf *MonthlyFiller) writeXLSX(w io.Writer) error {
+	var f *excelize.File
+	var sheet string
+
+	if isXLSX(mf.templateFile) {
+		var err error
+		f, err = excelize.OpenFile(mf.templateFile)
+		if err != nil {
+			return fmt.Errorf("failed to reopen %s: %v", mf.templateFile, err)
+		}
+		sheet = f.GetSheetList()[0]
+	} else {
+		// CSV source: build a plain workbook from the cell model
+		f = excelize.NewFile()
+		sheet = templateSheet
+		if err := f.SetSheetName(f.GetSheetName(0), sheet); err != nil {
+			return fmt.Errorf("failed to name sheet: %v", err)
+		}
+	}
+	defer f.Close()
+
+	for i, row := range mf.monthlyData {
+		for j, cell := range row {
+			if !cell.Written && (isXLSX(mf.templateFile) || cell.IsEmpty()) {
+				continue
+			}
+			name, _ := excelize.CoordinatesToCellName(j+1, i+1)
+			if err := setWorkbookCell(f, sheet, name, cell); err != nil {
+				return fmt.Errorf("failed to write %s: %v", name, err)
+			}
+		}
+	}
+
+	if mf.thresholds != nil {
+		if err := mf.applyHighlights(f, sheet); err != nil {
+			return err
+		}
+	}
+
+	if mf.charts {
+		if err := mf.writeCharts(f, sheet); err != nil {
+			return err
+		}
+	}
+
+	// Cached values of formulas are stale once inputs change; let Excel recalculate
+	fullCalc := true
+	if err := f.SetCalcProps(&excelize.CalcPropsOptions{FullCalcOnLoad: &fullCalc}); err != nil {
+		return fmt.Errorf("failed to set calculation properties: %v", err)
+	}
+
+	return f.Write(w)
+}
+
+// setWorkbookCell writes one cell with the type of the cell model. Formula cells
+// get the number the filler computed as their cached result, so readers that
+// don't recalculate (and the next run of the filler) still see it. excelize can't
+// cache a text result, so N/A results are left uncached instead of stale.
+func setWorkbookCell(f *excelize.File, sheet, name string, cell Cell) error {
+	switch cell.Kind {
+	case CellFormula:
+		var err error
+		if _, ok := cell.Number(); ok {
+			err = f.SetCellFloat(sheet, name, cell.Value, -1, 64)
+		} else {
+			err = f.SetCellDefault(sheet, name, "")
+		}
+		if err != nil {
+			return err
+		}
+		// SetCellFormula keeps the value set above as the cached result
+		return f.SetCellFormula(sheet, name, cell.Formula)
+	case CellNumber:
+		return f.SetCellFloat(sheet, name, cell.Value, -1, 64)
+	default:
+		return f.SetCellStr(sheet, name, cell.Text)
+	}
+}
+
+// summaryFormula is the Excel formula for a summary cell over the given phase cells,
+// showing N/A like the filler does when none of them holds a number
+func summaryFormula(function string, row int, cols ...int) string {
+	refs := make([]string, len(cols))
+	for i, col := range cols {
+		refs[i], _ = excelize.CoordinatesToCellName(col+1, row+1)
+	}
+	args := strings.Join(refs, ",")
+	return fmt.Sprintf(`IF(COUNT(%s)=0,"%s",%s(%s))`, args, noDataLabel, function, args)
+}
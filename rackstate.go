@@ -0,0 +1,63 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/reski-rukmantiyo/bdx-parser/pkg/inventory"
+)
+
+// noDataLabel is written in place of statistics for racks without usable samples
+const noDataLabel = "N/A"
+
+// DetectRackStates determines the state of racks Q1-Q18, preferring the inventory
+// and falling back to detection from the loaded samples
+func (dp *DataProcessor) DetectRackStates() {
+	dp.rackStates = make(map[string]inventory.RackState)
+
+	counts := make(map[inventory.RackState]int)
+	for i := 1; i <= 18; i++ {
+		rackName := fmt.Sprintf("Q%d", i)
+
+		state, declared := dp.inventory.State(dp.pduName, rackName)
+		if !declared {
+			state = dp.detectRackState(rackName)
+		}
+
+		dp.rackStates[rackName] = state
+		counts[state]++
+	}
+
+	fmt.Printf("Rack states for PDU %s: %d active, %d idle, %d unpopulated\n",
+		dp.pduName, counts[inventory.RackActive], counts[inventory.RackIdle], counts[inventory.RackUnpopulated])
+}
+
+// detectRackState classifies a rack from its L1/L2/L3 samples
+func (dp *DataProcessor) detectRackState(rackName string) inventory.RackState {
+	hasColumns := false
+	for _, lineType := range []string{"l1", "l2", "l3"} {
+		samples, exists := dp.data[fmt.Sprintf("%s_%s", rackName, lineType)]
+		if !exists {
+			continue
+		}
+		hasColumns = true
+
+		for _, s := range samples {
+			if s.Value != 0 {
+				return inventory.RackActive
+			}
+		}
+	}
+
+	if !hasColumns {
+		return inventory.RackUnpopulated
+	}
+	return inventory.RackIdle
+}
+
+// RackState returns the state of a rack, treating racks not yet classified as active
+func (dp *DataProcessor) RackState(rackName string) inventory.RackState {
+	if state, ok := dp.rackStates[rackName]; ok {
+		return state
+	}
+	return inventory.RackActive
+}
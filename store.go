@@ -0,0 +1,38 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/reski-rukmantiyo/bdx-parser/pkg/history"
+)
+
+// recordHistory stores the statistics of the processed PDU in the history database
+func (dp *DataProcessor) recordHistory(inputFile string) error {
+	if dp.store == "" {
+		return nil
+	}
+	if dp.periodStart.IsZero() {
+		return fmt.Errorf("cannot record %s: no parseable timestamps to place it in a period", dp.pduName)
+	}
+
+	hash, err := history.FileHash(inputFile)
+	if err != nil {
+		return fmt.Errorf("failed to hash %s: %v", inputFile, err)
+	}
+	entries, err := history.FromResult(dp.BuildResult(inputFile), hash, "")
+	if err != nil {
+		return err
+	}
+
+	store, err := history.Open(dp.store)
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+
+	if err := store.Record(entries); err != nil {
+		return fmt.Errorf("failed to record history: %v", err)
+	}
+	fmt.Printf("Recorded %d values for PDU %s in %s\n", len(entries), dp.pduName, dp.store)
+	return nil
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/reski-rukmantiyo/bdx-parser/pkg/inventory"
+)
+
+// Summary rules combining a rack's phases into one series
+const (
+	SummaryMean     = "mean"      // mean of the phases
+	SummarySum      = "sum"       // total amps across phases
+	SummaryMaxPhase = "max-phase" // the most loaded phase
+	SummaryKW       = "kw"        // sum of amps x voltage x power factor, in kW
+)
+
+// SummaryOptions controls the per-rack summary computed from the raw series
+type SummaryOptions struct {
+	Rule        string
+	Voltage     float64 // phase-to-neutral volts, kw rule only
+	PowerFactor float64 // kw rule only
+}
+
+// Validate checks the summary options
+func (opts SummaryOptions) Validate() error {
+	switch opts.Rule {
+	case SummaryMean, SummarySum, SummaryMaxPhase:
+	case SummaryKW:
+		if opts.Voltage <= 0 {
+			return fmt.Errorf("kw summary needs a positive voltage")
+		}
+		if opts.PowerFactor <= 0 || opts.PowerFactor > 1 {
+			return fmt.Errorf("power factor must be in (0, 1]")
+		}
+	default:
+		return fmt.Errorf("unknown summary rule %q (use mean, sum, max-phase or kw)", opts.Rule)
+	}
+	return nil
+}
+
+// Unit returns the unit of the summary values
+func (opts SummaryOptions) Unit() string {
+	if opts.Rule == SummaryKW {
+		return "kW"
+	}
+	return "A"
+}
+
+// combine merges the readings of one instant; zero readings are "no data" and
+// ignored, and 0 is returned when no phase has data
+func (opts SummaryOptions) combine(values []float64) float64 {
+	sum, max := 0.0, 0.0
+	count := 0
+	for _, v := range values {
+		if v == 0 {
+			continue
+		}
+		sum += v
+		if count == 0 || v > max {
+			max = v
+		}
+		count++
+	}
+	if count == 0 {
+		return 0
+	}
+
+	switch opts.Rule {
+	case SummarySum:
+		return sum
+	case SummaryMaxPhase:
+		return max
+	case SummaryKW:
+		return sum * opts.Voltage * opts.PowerFactor / 1000
+	default:
+		return sum / float64(count)
+	}
+}
+
+// RackSummarySeries combines the L1/L2/L3 series of a rack instant by instant, so
+// statistics of the result are coincident (e.g. the peak of the sum, not the sum of peaks)
+func (dp *DataProcessor) RackSummarySeries(rackName string) []Sample {
+	byTime := make(map[time.Time][]float64)
+	for _, lineType := range []string{"l1", "l2", "l3"} {
+		for _, s := range dp.data[fmt.Sprintf("%s_%s", rackName, lineType)] {
+			byTime[s.Time] = append(byTime[s.Time], s.Value)
+		}
+	}
+
+	times := make([]time.Time, 0, len(byTime))
+	for t := range byTime {
+		times = append(times, t)
+	}
+	sort.Slice(times, func(i, j int) bool { return times[i].Before(times[j]) })
+
+	series := make([]Sample, len(times))
+	for i, t := range times {
+		series[i] = Sample{Time: t, Value: dp.summary.combine(byTime[t])}
+	}
+	return series
+}
+
+// rackSummary returns the summary statistics of a rack; Count is 0 without data
+func (dp *DataProcessor) rackSummary(rackName string) Statistics {
+	if dp.RackState(rackName) != inventory.RackActive {
+		return Statistics{}
+	}
+	return dp.sampleStatistics(dp.RackSummarySeries(rackName))
+}
@@ -0,0 +1,262 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Sample is a single timestamped reading
+// Time is zero when the row's timestamp could not be parsed.
+type Sample struct {
+	Time  time.Time
+	Value float64
+}
+
+// timestampLayouts are the timestamp formats seen in PDU exports
+var timestampLayouts = []string{
+	"02/01/2006 15:04:05",
+	"02/01/2006 15:04",
+	"2/1/2006 15:04:05",
+	"2/1/2006 15:04",
+	"2006-01-02 15:04:05",
+	"2006-01-02 15:04",
+	"2006-01-02T15:04:05",
+	time.RFC3339,
+	"01-02-06 15:04",
+}
+
+// excelEpoch is day zero for Excel serial date numbers
+var excelEpoch = time.Date(1899, 12, 30, 0, 0, 0, 0, time.UTC)
+
+// parseTimestamp parses a timestamp cell, accepting text layouts and Excel serial numbers
+func parseTimestamp(cell string) (time.Time, error) {
+	cell = strings.TrimSpace(cell)
+	if cell == "" {
+		return time.Time{}, fmt.Errorf("empty timestamp")
+	}
+
+	for _, layout := range timestampLayouts {
+		if t, err := time.Parse(layout, cell); err == nil {
+			return t, nil
+		}
+	}
+
+	// Excel serial date, e.g. "45869.993055556"
+	if serial, err := strconv.ParseFloat(cell, 64); err == nil && serial > 0 {
+		seconds := math.Round(serial * 86400)
+		return excelEpoch.Add(time.Duration(seconds) * time.Second), nil
+	}
+
+	return time.Time{}, fmt.Errorf("unrecognised timestamp %q", cell)
+}
+
+// DetectInterval returns the native sampling interval as the median of the
+// positive gaps between consecutive distinct timestamps
+func DetectInterval(times []time.Time) time.Duration {
+	sorted := make([]time.Time, 0, len(times))
+	for _, t := range times {
+		if !t.IsZero() {
+			sorted = append(sorted, t)
+		}
+	}
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Before(sorted[j]) })
+
+	var gaps []time.Duration
+	for i := 1; i < len(sorted); i++ {
+		if gap := sorted[i].Sub(sorted[i-1]); gap > 0 {
+			gaps = append(gaps, gap)
+		}
+	}
+	if len(gaps) == 0 {
+		return 0
+	}
+
+	sort.Slice(gaps, func(i, j int) bool { return gaps[i] < gaps[j] })
+	return gaps[len(gaps)/2]
+}
+
+// Aggregation methods for combining samples that fall into one grid slot
+const (
+	AggregateMean = "mean"
+	AggregateLast = "last"
+	AggregateMax  = "max"
+)
+
+// ResampleOptions controls how series are mapped onto a fixed time grid
+type ResampleOptions struct {
+	Interval    time.Duration // grid spacing, e.g. 15m
+	Aggregation string        // mean, last or max
+	MaxGap      int           // max consecutive empty slots filled by linear interpolation
+}
+
+// Validate checks the resample options
+func (opts ResampleOptions) Validate() error {
+	if opts.Interval <= 0 {
+		return fmt.Errorf("resample interval must be positive")
+	}
+	switch opts.Aggregation {
+	case AggregateMean, AggregateLast, AggregateMax:
+	default:
+		return fmt.Errorf("unknown aggregation %q (use mean, last or max)", opts.Aggregation)
+	}
+	if opts.MaxGap < 0 {
+		return fmt.Errorf("max gap must not be negative")
+	}
+	return nil
+}
+
+// Resample maps samples onto a grid starting at gridStart with opts.Interval spacing.
+// Zero readings mean no data and are left out of the aggregation; a slot holding only
+// zero readings stays a zero reading, so outages remain visible and are never
+// interpolated across. Slots without samples are interpolated when the gap is at
+// most opts.MaxGap slots, otherwise they are left out of the result.
+func Resample(samples []Sample, gridStart time.Time, opts ResampleOptions) []Sample {
+	if len(samples) == 0 {
+		return nil
+	}
+
+	sorted := make([]Sample, len(samples))
+	copy(sorted, samples)
+	sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].Time.Before(sorted[j].Time) })
+
+	// Bucket samples by slot index
+	lastSlot := int(sorted[len(sorted)-1].Time.Sub(gridStart) / opts.Interval)
+	slots := make([][]float64, lastSlot+1)
+	dropout := make([]bool, len(slots)) // slot had only zero readings
+	for _, s := range sorted {
+		idx := int(s.Time.Sub(gridStart) / opts.Interval)
+		if idx < 0 {
+			continue
+		}
+		if s.Value == 0 {
+			dropout[idx] = true
+			continue
+		}
+		slots[idx] = append(slots[idx], s.Value)
+	}
+
+	values := make([]float64, len(slots))
+	filled := make([]bool, len(slots))
+	for i, slot := range slots {
+		if len(slot) > 0 {
+			values[i] = aggregate(slot, opts.Aggregation)
+			dropout[i] = false
+		}
+		filled[i] = len(slot) > 0 || dropout[i]
+	}
+
+	// Linear interpolation across short gaps, never from or to a dropout
+	prev := -1
+	for i := range slots {
+		if !filled[i] {
+			continue
+		}
+		if dropout[i] {
+			prev = -1
+			continue
+		}
+		if prev >= 0 && i-prev > 1 && i-prev-1 <= opts.MaxGap {
+			step := (values[i] - values[prev]) / float64(i-prev)
+			for j := prev + 1; j < i; j++ {
+				values[j] = values[prev] + step*float64(j-prev)
+				filled[j] = true
+			}
+		}
+		prev = i
+	}
+
+	var result []Sample
+	for i := range slots {
+		if filled[i] {
+			result = append(result, Sample{
+				Time:  gridStart.Add(time.Duration(i) * opts.Interval),
+				Value: values[i],
+			})
+		}
+	}
+	return result
+}
+
+// aggregate combines the values of one grid slot
+func aggregate(values []float64, method string) float64 {
+	switch method {
+	case AggregateLast:
+		return values[len(values)-1]
+	case AggregateMax:
+		max := values[0]
+		for _, v := range values[1:] {
+			if v > max {
+				max = v
+			}
+		}
+		return max
+	default:
+		sum := 0.0
+		for _, v := range values {
+			sum += v
+		}
+		return sum / float64(len(values))
+	}
+}
+
+// sampleValues extracts the reading values from samples
+func sampleValues(samples []Sample) []float64 {
+	values := make([]float64, len(samples))
+	for i, s := range samples {
+		values[i] = s.Value
+	}
+	return values
+}
+
+// ResampleAll resamples every loaded series onto a common grid aligned to opts.Interval
+func (dp *DataProcessor) ResampleAll(opts ResampleOptions) error {
+	if err := opts.Validate(); err != nil {
+		return err
+	}
+	if dp.unparsedTimestamps > 0 {
+		return fmt.Errorf("cannot resample: %d rows have unparseable timestamps", dp.unparsedTimestamps)
+	}
+
+	// Common grid start across all series so PDUs line up
+	var gridStart time.Time
+	for _, samples := range dp.data {
+		for _, s := range samples {
+			if gridStart.IsZero() || s.Time.Before(gridStart) {
+				gridStart = s.Time
+			}
+		}
+	}
+	if gridStart.IsZero() {
+		return nil
+	}
+	gridStart = gridStart.Truncate(opts.Interval)
+
+	for key, samples := range dp.data {
+		dp.data[key] = Resample(samples, gridStart, opts)
+	}
+
+	fmt.Printf("Resampled PDU %s from %s to %s grid (%s aggregation, interpolating gaps up to %d slots)\n",
+		dp.pduName, formatInterval(dp.interval), formatInterval(opts.Interval), opts.Aggregation, opts.MaxGap)
+	return nil
+}
+
+// sampleInterval returns the spacing of the loaded series: the resample grid when
+// resampling is enabled, otherwise the detected native interval
+func (dp *DataProcessor) sampleInterval() time.Duration {
+	if dp.resample != nil {
+		return dp.resample.Interval
+	}
+	return dp.interval
+}
+
+// formatInterval prints an interval for console output
+func formatInterval(d time.Duration) string {
+	if d <= 0 {
+		return "unknown"
+	}
+	return d.String()
+}